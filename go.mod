@@ -3,6 +3,7 @@ module github.com/lorentzforces/selfman
 go 1.25.3
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/lorentzforces/fresh-err v1.0.1
 	github.com/spf13/cobra v1.9.1
 	github.com/stretchr/testify v1.10.0
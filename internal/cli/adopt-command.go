@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/spf13/cobra"
+)
+
+const (
+	adoptCmdOptionName = "name"
+	adoptCmdOptionVersion = "version"
+
+	defaultAdoptedVersion = "adopted"
+)
+
+func CreateAdoptCmd() SelfmanCommand {
+	selfmanCmd := SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "adopt [flags] binary-path",
+			Short: "Bring an existing binary under selfman's management",
+		},
+		runFunc: runAdoptCmd,
+	}
+
+	selfmanCmd.cobraCmd.Flags().String(
+		adoptCmdOptionName,
+		"",
+		"Name of the application to create (defaults to the binary's file name)",
+	)
+	selfmanCmd.cobraCmd.Flags().String(
+		adoptCmdOptionVersion,
+		defaultAdoptedVersion,
+		"Version label to store the adopted binary under",
+	)
+
+	return selfmanCmd
+}
+
+func runAdoptCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
+	selfmanData, err := produceSelfmanData(cmd)
+	if err != nil { return nil, err }
+
+	if len(args) < 1 {
+		return nil,
+			fmt.Errorf("Adopt command expects a path to a binary, but one was not provided")
+	}
+	binaryPath, err := filepath.Abs(args[0])
+	run.AssertNoErrReason(err, "could not determine working directory")
+
+	name, err := cmd.Flags().GetString(adoptCmdOptionName)
+	run.AssertNoErr(err)
+	if len(name) == 0 {
+		name = path.Base(binaryPath)
+	}
+	version, err := cmd.Flags().GetString(adoptCmdOptionVersion)
+	run.AssertNoErr(err)
+
+	ops, err := adoptBinary(binaryPath, name, version, selfmanData)
+	if err != nil { return nil, err }
+
+	return &SelfmanResult{
+		textOutput: nil,
+		operations: ops,
+		systemConfig: selfmanData.SystemConfig,
+	}, nil
+}
+
+// Moves the binary into selfman's artifacts, replaces it with a link to its new location, and
+// writes a config for the new app.
+func adoptBinary(
+	binaryPath string,
+	name string,
+	version string,
+	selfmanData data.Selfman,
+) ([]ops.Operation, error) {
+	if _, configured := selfmanData.AppConfigs[name]; configured {
+		return nil, fmt.Errorf("An application named \"%s\" is already configured", name)
+	}
+
+	stat, err := os.Lstat(binaryPath)
+	if err != nil { return nil, fmt.Errorf("Could not adopt binary: %w", err) }
+	if stat.Mode() & os.ModeSymlink != 0 {
+		return nil, fmt.Errorf(
+			"\"%s\" is a symlink - adopt the file it points to instead",
+			binaryPath,
+		)
+	}
+	if !stat.Mode().IsRegular() || stat.Mode() & 0o111 == 0 {
+		return nil, fmt.Errorf("\"%s\" is not an executable file", binaryPath)
+	}
+
+	app := data.AppConfig{
+		SystemConfig: selfmanData.SystemConfig,
+		Name: name,
+		Flavor: data.FlavorBinaryFile,
+		Version: version,
+		BuildAction: data.ActionNone,
+	}
+	configContents := data.AppConfigFileContents(app)
+
+	// validate the new app config the same way it will be validated when it is loaded later
+	_, err = data.SelfmanFromValues(selfmanData.SystemConfig, []data.AppConfig{ app }, nil)
+	if err != nil { return nil, err }
+
+	configPath := selfmanData.SystemConfig.AppConfigFilePath(name)
+	if _, err := os.Lstat(configPath); err == nil {
+		return nil, fmt.Errorf("An app config file already exists at \"%s\"", configPath)
+	}
+
+	actions := []ops.Operation{
+		ops.MoveTarget{
+			SourcePath: binaryPath,
+			DestinationPath: app.ArtifactPath(),
+		},
+		ops.LinkArtifact{
+			SourcePath: app.ArtifactPath(),
+			DestinationPath: binaryPath,
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
+		},
+	}
+
+	// the binary may have been installed somewhere other than selfman's binary dir
+	if filepath.Clean(binaryPath) != filepath.Clean(app.BinaryPath()) {
+		actions = append(actions, app.GetLinkArtifactOps(ops.ClobberPolicy{})...)
+	}
+
+	actions = append(actions, ops.WriteFile{
+		TypeOfFile: "app config file",
+		Path: configPath,
+		Contents: configContents,
+		Mode: 0o644,
+	})
+
+	return actions, nil
+}
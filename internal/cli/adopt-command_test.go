@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/data/mocks"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdoptMovesBinaryAndWritesConfig(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	binaryPath := path.Join(*systemConfig.BinaryDir, "hand-installed")
+	touchFile(binaryPath)
+
+	selfmanData, err := data.SelfmanFromValues(systemConfig, nil, &mocks.MockManagedFiles{})
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	actions, err := adoptBinary(binaryPath, "hand-installed", "1.2.3", selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	artifactPath := path.Join(systemConfig.ArtifactsPath(), "hand-installed---1.2.3")
+	configPath := path.Join(*systemConfig.AppConfigDir, "hand-installed.config.yaml")
+	expectedActions := []ops.Operation{
+		ops.MoveTarget{
+			SourcePath: binaryPath,
+			DestinationPath: artifactPath,
+		},
+		ops.LinkArtifact{
+			SourcePath: artifactPath,
+			DestinationPath: binaryPath,
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		ops.WriteFile{
+			TypeOfFile: "app config file",
+			Path: configPath,
+			Contents:
+				"name: hand-installed\n" +
+				"flavor: binary-file\n" +
+				"version: 1.2.3\n" +
+				"build-action: none\n",
+			Mode: 0o644,
+		},
+	}
+	assert.Equal(t, expectedActions, actions)
+
+	for _, action := range actions {
+		_, err := action.Execute(t.Context())
+		assert.NoError(t, err)
+		run.BailIfFailed(t)
+	}
+
+	// the adopted app should now look like any other fully-installed app
+	adoptedData, err := data.Produce(data.ConfigOverrides{ System: *systemConfig })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	_, status := adoptedData.AppStatus("hand-installed")
+	assert.Equal(t, data.AppStatusLinkPresent, status.Label())
+
+	removeActions, err := removeApp("hand-installed", true, adoptedData)
+	assert.NoError(t, err)
+	for _, action := range removeActions {
+		_, isDeleteDir := action.(ops.DeleteDir)
+		assert.False(t, isDeleteDir, "Adopted apps have no source dir to delete")
+	}
+}
+
+func TestAdoptRejectsConfiguredNamesAndNonExecutables(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	existingApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "existing",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ existingApp },
+		&mocks.MockManagedFiles{},
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	binaryPath := path.Join(*systemConfig.BinaryDir, "existing")
+	touchFile(binaryPath)
+	_, err = adoptBinary(binaryPath, "existing", defaultAdoptedVersion, selfmanData)
+	assert.Error(t, err, "Adopting must not clash with an already-configured app")
+
+	notExecutablePath := path.Join(*systemConfig.BinaryDir, "not-executable")
+	run.AssertNoErr(os.WriteFile(notExecutablePath, []byte("text"), 0o644))
+	_, err = adoptBinary(notExecutablePath, "not-executable", defaultAdoptedVersion, selfmanData)
+	assert.Error(t, err, "Only executable files can be adopted")
+}
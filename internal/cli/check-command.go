@@ -2,25 +2,38 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/run"
 	"github.com/spf13/cobra"
 )
 
+const checkCmdOptionShadowing = "shadowing"
+
 func CreateCheckCmd() SelfmanCommand {
-	return SelfmanCommand{
+	selfmanCmd := SelfmanCommand{
 		cobraCmd: &cobra.Command{
 			Use: "check [flags] app-name",
 			Short: "Get detailed information about an application",
 		},
 		runFunc: runCheckCmd,
 	}
+
+	selfmanCmd.cobraCmd.Flags().Bool(
+		checkCmdOptionShadowing,
+		false,
+		"Report other binaries on PATH with the same name, and which one wins",
+	)
+
+	return selfmanCmd
 }
 
 func runCheckCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 	if err := validatePrereqs(); err != nil { return nil, err }
-	selfmanData, err := data.Produce()
+	selfmanData, err := produceSelfmanData(cmd)
 	if err != nil { return nil, err }
 
 	if len(args) < 1 {
@@ -30,6 +43,15 @@ func runCheckCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 	result, err := checkApp(args[0], selfmanData)
 	if err != nil { return nil, err }
 
+	showShadowing, err := cmd.Flags().GetBool(checkCmdOptionShadowing)
+	run.AssertNoErr(err)
+	if showShadowing {
+		result.shadowing = make([]shadowingReport, 0, len(result.status.Links))
+		for _, link := range result.status.Links {
+			result.shadowing = append(result.shadowing, checkShadowing(link, os.Getenv("PATH")))
+		}
+	}
+
 	return &SelfmanResult{
 		textOutput: result,
 		operations: nil,
@@ -39,7 +61,10 @@ func runCheckCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 type checkAppResult struct {
 	appName string
 	appIsLib bool
+	versionIsOverridden bool
 	status data.AppStatus
+	// Only present if a shadowing report was requested (one per link)
+	shadowing []shadowingReport
 }
 
 func (self checkAppResult) String() string {
@@ -47,6 +72,10 @@ func (self checkAppResult) String() string {
 	if len(self.status.AvailableVersions) > 0 {
 		versionsString = strings.Join(self.status.AvailableVersions, ", ")
 	}
+	versionLabel := self.status.DesiredVersion
+	if self.versionIsOverridden {
+		versionLabel += " (local override)"
+	}
 	resultString := fmt.Sprintf(
 		"📋 %s\n" +
 		"  version: %s\n\n" +
@@ -54,19 +83,92 @@ func (self checkAppResult) String() string {
 		"  Source present: %t\n" +
 		"  Target present: %t\n" +
 		"  Bin link present: %t\n",
-		self.appName, self.status.DesiredVersion, self.status.Label(),
+		self.appName, versionLabel, self.status.Label(),
 		self.status.SourcePresent, self.status.TargetPresent, self.status.LinkPresent,
 	)
 
+	if len(self.status.Links) > 1 {
+		for _, link := range self.status.Links {
+			resultString += fmt.Sprintf("    %s link present: %t\n", link.LinkName, link.Present)
+			if link.Foreign {
+				resultString += fmt.Sprintf(
+					"    ⚠ %s link path: %s\n",
+					link.LinkName, data.AppStatusForeignLink,
+				)
+			}
+		}
+	} else if self.status.LinkIsForeign {
+		resultString += fmt.Sprintf("  ⚠ Bin link path: %s\n", data.AppStatusForeignLink)
+	}
+
 	if self.appIsLib {
 		resultString += fmt.Sprintf("  Lib link present: %t\n", self.status.LibLinkPresent)
+		if self.status.LibLinkIsForeign {
+			resultString += fmt.Sprintf("  ⚠ Lib link path: %s\n", data.AppStatusForeignLink)
+		}
 	}
 
 	resultString += fmt.Sprintf("Available versions (locally): %s\n", versionsString)
 
+	builtVersions := make([]string, 0, len(self.status.BuiltVersions))
+	for _, version := range self.status.BuiltVersions {
+		if version == self.status.LinkedVersion {
+			version += " (active)"
+		}
+		builtVersions = append(builtVersions, version)
+	}
+	builtVersionsString := "None!"
+	if len(builtVersions) > 0 {
+		builtVersionsString = strings.Join(builtVersions, ", ")
+	}
+	resultString += fmt.Sprintf("Built versions: %s\n", builtVersionsString)
+
+	for _, report := range self.shadowing {
+		resultString += "\n" + report.String()
+	}
+
 	return resultString
 }
 
+type shadowingReport struct {
+	linkName string
+	linkPath string
+	linkPresent bool
+	// Whether the link is found when searching PATH at all
+	linkOnPath bool
+	// Binaries which will be run instead of selfman's link
+	shadowedBy []string
+	// Binaries found on PATH after selfman's link, which it is hiding
+	shadows []string
+}
+
+func (self shadowingReport) String() string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("Shadowing (%s):\n", self.linkName))
+
+	if !self.linkPresent {
+		buf.WriteString(fmt.Sprintf("%sNo selfman link present at %s\n", run.IndentChars, self.linkPath))
+	} else if !self.linkOnPath {
+		buf.WriteString(fmt.Sprintf("%s⚠ Link is not on PATH: %s\n", run.IndentChars, self.linkPath))
+	}
+
+	for _, binPath := range self.shadowedBy {
+		buf.WriteString(
+			fmt.Sprintf("%s⚠ Runs instead of selfman's link: %s\n", run.IndentChars, binPath),
+		)
+	}
+	for _, binPath := range self.shadows {
+		buf.WriteString(fmt.Sprintf("%sHidden by selfman's link: %s\n", run.IndentChars, binPath))
+	}
+	if self.linkPresent && len(self.shadowedBy) == 0 && len(self.shadows) == 0 {
+		buf.WriteString(
+			fmt.Sprintf("%sNo other binaries with this name are on PATH\n", run.IndentChars),
+		)
+	}
+
+	return buf.String()
+}
+
 func checkApp(name string, selfmanData data.Selfman) (checkAppResult, error) {
 	app, status := selfmanData.AppStatus(name)
 	if !status.IsConfigured {
@@ -79,6 +181,33 @@ func checkApp(name string, selfmanData data.Selfman) (checkAppResult, error) {
 	return checkAppResult{
 		appName: name,
 		appIsLib: app.LinkSourceAsLib,
+		versionIsOverridden: app.VersionIsOverridden,
 		status: status,
 	}, nil
 }
+
+// Compares one of the app's binary links against every other executable on PATH with the same
+// name.
+func checkShadowing(link data.LinkStatus, pathEnv string) shadowingReport {
+	report := shadowingReport{
+		linkName: link.LinkName,
+		linkPath: link.Path,
+		linkPresent: link.Present,
+	}
+	if !report.linkPresent { return report }
+
+	for _, binPath := range run.FindExecutablesOnPath(link.LinkName, pathEnv) {
+		if filepath.Clean(binPath) == filepath.Clean(report.linkPath) {
+			report.linkOnPath = true
+			continue
+		}
+		if report.linkOnPath {
+			report.shadows = append(report.shadows, binPath)
+		} else {
+			// if the link never turns up on PATH, anything found will be run instead of it
+			report.shadowedBy = append(report.shadowedBy, binPath)
+		}
+	}
+
+	return report
+}
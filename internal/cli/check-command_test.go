@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"path"
 	"testing"
 
 	"github.com/lorentzforces/selfman/internal/data"
@@ -104,3 +105,35 @@ func TestCheckShowsLibLinkStatusIfAppIsLib(t *testing.T) {
 
 	assert.Equal(t, true, result.status.LibLinkPresent)
 }
+
+func TestCheckShadowingReportsBinariesOnEitherSideOfLink(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+	earlierDir := t.TempDir()
+	laterDir := t.TempDir()
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "shadowing-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+	touchFile(app.BinaryPath())
+	touchFile(path.Join(earlierDir, app.Name))
+	touchFile(path.Join(laterDir, app.Name))
+	link := data.LinkStatus{ LinkName: app.Name, Path: app.BinaryPath(), Present: true }
+
+	report := checkShadowing(
+		link,
+		earlierDir + ":" + *systemConfig.BinaryDir + ":" + laterDir,
+	)
+	assert.True(t, report.linkOnPath)
+	assert.Equal(t, []string{ path.Join(earlierDir, app.Name) }, report.shadowedBy)
+	assert.Equal(t, []string{ path.Join(laterDir, app.Name) }, report.shadows)
+
+	report = checkShadowing(link, laterDir)
+	assert.False(t, report.linkOnPath)
+	assert.Equal(t, []string{ path.Join(laterDir, app.Name) }, report.shadowedBy)
+	assert.Empty(t, report.shadows)
+}
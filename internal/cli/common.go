@@ -1,10 +1,16 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/lorentzforces/selfman/internal/data"
 	"github.com/lorentzforces/selfman/internal/git"
 	"github.com/lorentzforces/selfman/internal/ops"
 	"github.com/lorentzforces/selfman/internal/run"
@@ -21,13 +27,89 @@ func validatePrereqs() error {
 type SelfmanCommand struct {
 	cobraCmd *cobra.Command
 	runFunc func(*cobra.Command, []string) (*SelfmanResult, error)
+	subCommands []SelfmanCommand
+}
+
+// Produces selfman's data, taking into account any global config override flags.
+func produceSelfmanData(cmd *cobra.Command) (data.Selfman, error) {
+	return data.Produce(configOverridesFromFlags(cmd))
+}
+
+func configOverridesFromFlags(cmd *cobra.Command) data.ConfigOverrides {
+	overrides := data.ConfigOverrides{}
+	overrides.ConfigPath = flagValueIfSet(cmd, globalOptionConfig)
+	overrides.Profile = flagPtrIfSet(cmd, globalOptionProfile)
+	overrides.System.DataDir = flagPtrIfSet(cmd, globalOptionDataDir)
+	overrides.System.BinaryDir = flagPtrIfSet(cmd, globalOptionBinDir)
+	overrides.System.LibDir = flagPtrIfSet(cmd, globalOptionLibDir)
+	overrides.System.AppConfigDir = flagPtrIfSet(cmd, globalOptionAppConfigDir)
+	return overrides
+}
+
+func flagValueIfSet(cmd *cobra.Command, flagName string) string {
+	if !cmd.Flags().Changed(flagName) { return "" }
+	value, err := cmd.Flags().GetString(flagName)
+	run.AssertNoErr(err)
+	return value
+}
+
+func flagPtrIfSet(cmd *cobra.Command, flagName string) *string {
+	if !cmd.Flags().Changed(flagName) { return nil }
+	return run.StrPtr(flagValueIfSet(cmd, flagName))
+}
+
+const (
+	linkOptionForce = "force"
+	linkOptionBackup = "backup"
+)
+
+// Adds flags for commands which place links (or other files) outside of selfman's data dir.
+func addClobberFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(
+		linkOptionForce,
+		false,
+		"Replace existing files at link destinations, even if they were not created by selfman " +
+			"(directories are only replaced along with --backup)",
+	)
+	cmd.Flags().Bool(
+		linkOptionBackup,
+		false,
+		"When replacing files not created by selfman, move them into selfman's backup dir " +
+			"instead of deleting them",
+	)
+}
+
+func clobberPolicyFromFlags(cmd *cobra.Command, system *data.SystemConfig) ops.ClobberPolicy {
+	force, err := cmd.Flags().GetBool(linkOptionForce)
+	run.AssertNoErr(err)
+	backup, err := cmd.Flags().GetBool(linkOptionBackup)
+	run.AssertNoErr(err)
+
+	clobber := ops.ClobberPolicy{ Force: force }
+	if backup {
+		clobber.BackupDir = system.BackupsPath()
+	}
+	return clobber
+}
+
+// For apps whose artifact selfman cannot (re-)create itself.
+func unobtainableArtifactError(app data.AppConfig) error {
+	return fmt.Errorf(
+		"Application \"%s\" (flavor %s) has no artifact present, and selfman cannot obtain " +
+			"one - adopt the binary again to restore it",
+		app.Name, app.Flavor,
+	)
 }
 
 type SelfmanResult struct {
 	// Text output is always printed before any other messages
 	textOutput fmt.Stringer
+	// Printed after the operations have been executed (or listed, for a dry run)
+	followUpOutput fmt.Stringer
 	// Any mutating operations to be executed as a result of running this command
 	operations []ops.Operation
+	// The system config the operations apply to. Must be set if there are any operations.
+	systemConfig *data.SystemConfig
 }
 
 func (self *SelfmanCommand) RunSelfmanCommand(cmd *cobra.Command, args []string) error {
@@ -50,17 +132,67 @@ func (self *SelfmanCommand) RunSelfmanCommand(cmd *cobra.Command, args []string)
 
 	if dryRun {
 		dryRunOperations(cmdResult.operations, verbosity)
+		printFollowUp(cmdResult.followUpOutput)
+		return nil
+	}
+
+	if len(cmdResult.operations) == 0 {
+		printFollowUp(cmdResult.followUpOutput)
 		return nil
-	} else {
-		return executeOperations(cmdResult.operations, verbosity)
 	}
+
+	run.Assert(
+		cmdResult.systemConfig != nil,
+		"a command which produces operations must provide its system config",
+	)
+	releaseLock, err := run.AcquireFileLock(cmdResult.systemConfig.LockPath())
+	if err != nil { return err }
+	defer releaseLock()
+
+	ctx, stopHandlingSignals := interruptibleContext()
+	defer stopHandlingSignals()
+	err = executeOperations(ctx, cmdResult.operations, verbosity)
+	if err != nil { return err }
+	printFollowUp(cmdResult.followUpOutput)
+	return nil
 }
 
-// Since the messages printed herein are progress updates, print to stderr
-func executeOperations(actions []ops.Operation, verbosity VerbosityLevel) error {
-	for _, action := range actions {
-		fmt.Fprintln(os.Stderr, printOperation(action, verbosity))
-		msg, err := action.Execute()
+func printFollowUp(followUpOutput fmt.Stringer) {
+	if followUpOutput == nil { return }
+	fmt.Println()
+	fmt.Println(followUpOutput)
+}
+
+// Since the messages printed herein are progress updates, print to stderr. When verbose, output
+// from the commands operations run is streamed as well; otherwise, if stderr is a terminal, the
+// running operation is shown along with how long it has been running.
+func executeOperations(
+	ctx context.Context,
+	actions []ops.Operation,
+	verbosity VerbosityLevel,
+) error {
+	if verbosity == Verbose {
+		run.StreamCmdOutput(os.Stderr)
+		defer run.StreamCmdOutput(nil)
+	}
+	showProgress := verbosity == NotVerbose && isTerminal(os.Stderr)
+
+	for i, action := range actions {
+		if ctx.Err() != nil {
+			if i == 0 { return context.Cause(ctx) }
+			return ops.InterruptedAfter(ctx, actions[i - 1])
+		}
+
+		var msg string
+		var err error
+		if showProgress {
+			msg, err = executeWithProgress(ctx, action, os.Stderr, time.Second)
+			fmt.Fprintln(os.Stderr, printOperation(action, verbosity))
+		} else {
+			fmt.Fprintln(os.Stderr, printOperation(action, verbosity))
+			msg, err = action.Execute(ctx)
+		}
+		err = ops.AttributeInterruption(ctx, action, err)
 		if err != nil { return err }
 
 		fmt.Fprintf(os.Stderr, "✓")
@@ -73,6 +205,79 @@ func executeOperations(actions []ops.Operation, verbosity VerbosityLevel) error
 	return nil
 }
 
+// Executes the operation while showing its top line and how long it has been running, on a
+// single line which is rewritten every interval. The line is cleared once the operation finishes.
+func executeWithProgress(
+	ctx context.Context,
+	action ops.Operation,
+	out io.Writer,
+	interval time.Duration,
+) (string, error) {
+	topLine := action.Describe().TopLine
+	start := time.Now()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			elapsed := time.Since(start).Truncate(time.Second)
+			fmt.Fprintf(out, "%s⏳ %s (%s)", clearLine, topLine, elapsed)
+			select {
+			case <-stop: {
+				fmt.Fprint(out, clearLine)
+				return
+			}
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	msg, err := action.Execute(ctx)
+	close(stop)
+	<-stopped
+	return msg, err
+}
+
+// The returned context is cancelled (with run.ErrInterrupted as its cause) when selfman is
+// interrupted, e.g. by Ctrl-C. Only the first interruption is caught, so that a second one quits
+// selfman immediately if stopping the current operation is taking too long.
+func interruptibleContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals: {
+			signal.Stop(signals)
+			fmt.Fprintln(
+				os.Stderr,
+				"\nInterrupted, stopping the current operation " +
+					"(interrupt again to quit immediately)",
+			)
+			cancel(run.ErrInterrupted)
+		}
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
+
+// Returns the cursor to the start of the line, and erases the line.
+const clearLine = "\r\033[K"
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode() & os.ModeCharDevice != 0
+}
+
 type VerbosityLevel int
 const (
 	Verbose VerbosityLevel = iota
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+type slowOp struct {
+	duration time.Duration
+}
+
+func (self slowOp) Execute(ctx context.Context) (string, error) {
+	time.Sleep(self.duration)
+	return "done", nil
+}
+
+func (self slowOp) Describe() ops.OpDescription {
+	return ops.OpDescription{ TopLine: "Take a while" }
+}
+
+func TestProgressShowsRunningOperationThenClears(t *testing.T) {
+	var out bytes.Buffer
+	msg, err := executeWithProgress(
+		t.Context(),
+		slowOp{ 50 * time.Millisecond },
+		&out,
+		10 * time.Millisecond,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", msg)
+
+	assert.True(t, strings.HasPrefix(out.String(), clearLine + "⏳ Take a while (0s)"))
+	assert.Greater(
+		t,
+		strings.Count(out.String(), "⏳ Take a while"),
+		1,
+		"The progress line is rewritten while the operation runs",
+	)
+	assert.True(t, strings.HasSuffix(out.String(), clearLine))
+}
+
+// Stands in for the user interrupting selfman while (or just after) the operation runs.
+type interruptingOp struct {
+	cancel context.CancelCauseFunc
+	executed *bool
+}
+
+func (self interruptingOp) Execute(ctx context.Context) (string, error) {
+	*self.executed = true
+	self.cancel(run.ErrInterrupted)
+	return "", nil
+}
+
+func (self interruptingOp) Describe() ops.OpDescription {
+	return ops.OpDescription{ TopLine: "Get interrupted" }
+}
+
+func TestInterruptionStopsRemainingOperations(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(t.Context())
+	var firstExecuted, secondExecuted bool
+	actions := []ops.Operation{
+		interruptingOp{ cancel: cancel, executed: &firstExecuted },
+		interruptingOp{ cancel: cancel, executed: &secondExecuted },
+	}
+
+	err := executeOperations(ctx, actions, NotVerbose)
+	assert.True(t, firstExecuted)
+	assert.False(t, secondExecuted)
+	assert.ErrorIs(t, err, run.ErrInterrupted)
+	assert.EqualError(
+		t,
+		err,
+		"Interrupted after operation \"Get interrupted\" finished, " +
+			"no further operations were executed",
+	)
+}
+
+func TestInterruptionNamesRunningOperation(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(t.Context())
+	time.AfterFunc(100 * time.Millisecond, func() { cancel(run.ErrInterrupted) })
+	actions := []ops.Operation{
+		ops.BuildWithScript{
+			SourcePath: t.TempDir(),
+			ScriptShell: "/bin/sh",
+			ScriptCmd: "sleep 30",
+		},
+	}
+
+	err := executeOperations(ctx, actions, NotVerbose)
+	var interrupted ops.InterruptedError
+	assert.True(t, errors.As(err, &interrupted))
+	run.BailIfFailed(t)
+	assert.False(t, interrupted.Finished)
+	assert.Equal(t, actions[0].Describe().TopLine, interrupted.Operation)
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/spf13/cobra"
+)
+
+func CreateConfigCmd() SelfmanCommand {
+	return SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "config",
+			Short: "Inspect selfman's own configuration",
+		},
+		subCommands: []SelfmanCommand{
+			CreateConfigPathsCmd(),
+		},
+	}
+}
+
+func CreateConfigPathsCmd() SelfmanCommand {
+	return SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "paths",
+			Short: "Print each effective system setting and where its value came from",
+		},
+		runFunc: runConfigPathsCmd,
+	}
+}
+
+func runConfigPathsCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
+	systemConfig, origins, err := data.LoadSystemConfig(configOverridesFromFlags(cmd))
+	if err != nil { return nil, err }
+
+	return &SelfmanResult{
+		textOutput: configPathsResult{
+			configFilePath: origins.ConfigFilePath,
+			configFileOrigin: origins.ConfigFileOrigin,
+			profile: systemConfig.Profile,
+			profileOrigin: origins.ProfileOrigin,
+			settings: origins.EffectiveSettings(&systemConfig),
+		},
+		operations: nil,
+	}, nil
+}
+
+type configPathsResult struct {
+	configFilePath string
+	configFileOrigin string
+	profile string
+	profileOrigin string
+	settings []data.EffectiveSetting
+}
+
+func (self configPathsResult) String() string {
+	var buf strings.Builder
+	writer := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	if len(self.configFilePath) > 0 {
+		fmt.Fprintf(writer, "config file:\t%s\t(%s)\n", self.configFilePath, self.configFileOrigin)
+	} else {
+		fmt.Fprintf(writer, "config file:\tnone found\n")
+	}
+	if len(self.profile) > 0 {
+		fmt.Fprintf(writer, "profile:\t%s\t(%s)\n", self.profile, self.profileOrigin)
+	}
+	for _, setting := range self.settings {
+		fmt.Fprintf(writer, "%s:\t%s\t(%s)\n", setting.Key, setting.Value, setting.Origin)
+	}
+
+	writer.Flush()
+	return buf.String()
+}
@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigOverridesAreLayeredWithOrigins(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := path.Join(configDir, "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte("data-dir: /from/file/data\nlib-dir: /from/file/lib\nbinary-dir: /from/file/bin\n"),
+		0o644,
+	)
+	run.AssertNoErr(err)
+
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("SELFMAN_LIB_DIR", "/from/env/lib")
+	t.Setenv("SELFMAN_BIN_DIR", "/from/env/bin")
+
+	rootCmd := CreateRootCmd()
+	err = rootCmd.ParseFlags([]string{ "--config", configPath, "--bin-dir", "/from/flag/bin" })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	systemConfig, origins, err :=
+		data.LoadSystemConfig(configOverridesFromFlags(rootCmd))
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	assert.Equal(t, configPath, origins.ConfigFilePath)
+	assert.Equal(t, "flag --config", origins.ConfigFileOrigin)
+
+	assert.Equal(t, "/from/file/data", *systemConfig.DataDir)
+	assert.Equal(t, "/from/env/lib", *systemConfig.LibDir)
+	assert.Equal(t, "/from/flag/bin", *systemConfig.BinaryDir)
+
+	assert.Equal(t, data.OriginConfigFile, origins.Settings["data-dir"])
+	assert.Equal(t, "env var SELFMAN_LIB_DIR", origins.Settings["lib-dir"])
+	assert.Equal(t, "flag --bin-dir", origins.Settings["binary-dir"])
+	assert.Equal(t, data.OriginDefault, origins.Settings["script-shell"])
+}
+
+func TestConfigFlagMustPointToAFile(t *testing.T) {
+	rootCmd := CreateRootCmd()
+	err := rootCmd.ParseFlags([]string{ "--config", path.Join(t.TempDir(), "nothing-here.yaml") })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	_, _, err = data.LoadSystemConfig(configOverridesFromFlags(rootCmd))
+	assert.Error(t, err, "A config path provided via flag must exist")
+}
+
+func TestProfileSettingsLayerOverTopLevelSettings(t *testing.T) {
+	configDir := t.TempDir()
+	run.AssertNoErr(os.Mkdir(path.Join(configDir, "selfman"), 0o755))
+	configPath := path.Join(configDir, "selfman", "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(
+			"binary-dir: /top-level/bin\n" +
+			"lib-dir: /top-level/lib\n" +
+			"profiles:\n" +
+			"  work:\n" +
+			"    binary-dir: /work/bin\n",
+		),
+		0o644,
+	)
+	run.AssertNoErr(err)
+
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("XDG_DATA_HOME", "/xdg-data")
+	t.Setenv("SELFMAN_PROFILE", "work")
+
+	systemConfig, origins, err := data.LoadSystemConfig(data.ConfigOverrides{})
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	assert.Equal(t, "work", systemConfig.Profile)
+	assert.Equal(t, "env var SELFMAN_PROFILE", origins.ProfileOrigin)
+	assert.Equal(t, "/work/bin", *systemConfig.BinaryDir)
+	assert.Equal(t, "profile \"work\"", origins.Settings["binary-dir"])
+	assert.Equal(t, "/top-level/lib", *systemConfig.LibDir)
+	assert.Equal(t, "/xdg-data/selfman/profiles/work", *systemConfig.DataDir)
+	assert.Equal(
+		t,
+		path.Join(configDir, "selfman", "apps", "profiles", "work"),
+		*systemConfig.AppConfigDir,
+	)
+	assert.Equal(t, "default, within profiles/work", origins.Settings["data-dir"])
+
+	// explicitly selecting no profile takes priority over the environment
+	systemConfig, _, err = data.LoadSystemConfig(data.ConfigOverrides{ Profile: run.StrPtr("") })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Equal(t, "", systemConfig.Profile)
+	assert.Equal(t, "/top-level/bin", *systemConfig.BinaryDir)
+	assert.Equal(t, "/xdg-data/selfman", *systemConfig.DataDir)
+
+	_, _, err = data.LoadSystemConfig(data.ConfigOverrides{ Profile: run.StrPtr("personal") })
+	assert.Error(t, err, "Selecting a profile which is not configured must be an error")
+}
+
+func TestProfileDirsAreWithinTopLevelDirs(t *testing.T) {
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(
+		configPath,
+		[]byte(
+			"data-dir: /top-level/data\n" +
+			"app-config-dir: /top-level/apps\n" +
+			"profiles:\n" +
+			"  work: {}\n" +
+			"  personal:\n" +
+			"    data-dir: /personal/data\n",
+		),
+		0o644,
+	)
+	run.AssertNoErr(err)
+
+	overrides := data.ConfigOverrides{ ConfigPath: configPath, Profile: run.StrPtr("work") }
+	systemConfig, origins, err := data.LoadSystemConfig(overrides)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Equal(t, "/top-level/data/profiles/work", *systemConfig.DataDir)
+	assert.Equal(t, "/top-level/apps/profiles/work", *systemConfig.AppConfigDir)
+	assert.Equal(t, "/top-level/data/profiles/work/meta/selfman.lock", systemConfig.LockPath())
+	assert.Equal(t, "config file, within profiles/work", origins.Settings["data-dir"])
+
+	overrides.Profile = run.StrPtr("personal")
+	systemConfig, _, err = data.LoadSystemConfig(overrides)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Equal(t, "/personal/data", *systemConfig.DataDir)
+	assert.Equal(t, "/top-level/apps/profiles/personal", *systemConfig.AppConfigDir)
+}
+
+func TestLinkModeMustBeValid(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	t.Setenv("SELFMAN_LINK_MODE", data.LinkModeRelative)
+	systemConfig, _, err := data.LoadSystemConfig(data.ConfigOverrides{})
+	assert.NoError(t, err)
+	assert.Equal(t, data.LinkModeRelative, *systemConfig.LinkMode)
+
+	t.Setenv("SELFMAN_LINK_MODE", "hardlink")
+	_, _, err = data.LoadSystemConfig(data.ConfigOverrides{})
+	assert.ErrorContains(t, err, "SELFMAN_LINK_MODE")
+}
+
+func TestNetworkSettingsAreValidatedAndOverriddenPerApp(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := path.Join(configDir, "config.yaml")
+	run.AssertNoErr(os.WriteFile(
+		configPath,
+		[]byte("network-timeout: 30m\nnetwork-retries: 4\n"),
+		0o644,
+	))
+	t.Setenv("SELFMAN_NETWORK_BACKOFF", "soon")
+
+	rootCmd := CreateRootCmd()
+	err := rootCmd.ParseFlags([]string{ "--config", configPath })
+	run.AssertNoErr(err)
+	_, _, err = data.LoadSystemConfig(configOverridesFromFlags(rootCmd))
+	assert.ErrorContains(t, err, "Invalid network backoff \"soon\"")
+	assert.ErrorContains(t, err, "env var SELFMAN_NETWORK_BACKOFF")
+
+	t.Setenv("SELFMAN_NETWORK_BACKOFF", "5s")
+	systemConfig, _, err := data.LoadSystemConfig(configOverridesFromFlags(rootCmd))
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	app := data.AppConfig{ SystemConfig: &systemConfig, Name: "hotel" }
+	policy := app.NetworkPolicy()
+	assert.Equal(t, 30 * time.Minute, policy.Timeout)
+	assert.Equal(t, "network-timeout setting", policy.TimeoutSource)
+	assert.Equal(t, 4, policy.Retries)
+	assert.Equal(t, 5 * time.Second, policy.Backoff)
+
+	noRetries := 0
+	app.NetworkTimeout = "2h"
+	app.NetworkRetries = &noRetries
+	policy = app.NetworkPolicy()
+	assert.Equal(t, 2 * time.Hour, policy.Timeout)
+	assert.Equal(t, "network-timeout setting of app hotel", policy.TimeoutSource)
+	assert.Equal(t, 0, policy.Retries)
+	assert.Equal(t, 5 * time.Second, policy.Backoff)
+}
+
+func TestNetworkRetriesMustBeAWholeNumber(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := path.Join(configDir, "config.yaml")
+	run.AssertNoErr(os.WriteFile(configPath, []byte("network-retries: lots\n"), 0o644))
+	overrides := data.ConfigOverrides{ ConfigPath: configPath }
+
+	_, _, err := data.LoadSystemConfig(overrides)
+	assert.ErrorContains(t, err, "Error parsing config file")
+
+	run.AssertNoErr(os.WriteFile(configPath, []byte("network-retries: -1\n"), 0o644))
+	_, _, err = data.LoadSystemConfig(overrides)
+	assert.ErrorContains(t, err, "Invalid network retry count -1")
+	assert.ErrorContains(t, err, "config file")
+
+	run.AssertNoErr(os.WriteFile(configPath, []byte("network-retries: 3\n"), 0o644))
+	t.Setenv("SELFMAN_NETWORK_RETRIES", "lots")
+	_, _, err = data.LoadSystemConfig(overrides)
+	assert.ErrorContains(t, err, "Invalid network-retries \"lots\", must be a whole number")
+	assert.ErrorContains(t, err, "env var SELFMAN_NETWORK_RETRIES")
+
+	t.Setenv("SELFMAN_NETWORK_RETRIES", "5")
+	systemConfig, origins, err := data.LoadSystemConfig(overrides)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Equal(t, 5, *systemConfig.NetworkRetries)
+	for _, setting := range origins.EffectiveSettings(&systemConfig) {
+		if setting.Key != "network-retries" { continue }
+		assert.Equal(t, "5", setting.Value)
+		assert.Equal(t, "env var SELFMAN_NETWORK_RETRIES", setting.Origin)
+	}
+}
@@ -0,0 +1,359 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/git"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/spf13/cobra"
+)
+
+const doctorCmdOptionFix = "fix"
+
+func CreateDoctorCmd() SelfmanCommand {
+	selfmanCmd := SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "doctor",
+			Short: "Audit all files managed by selfman, as well as selfman's environment",
+		},
+		runFunc: runDoctorCmd,
+	}
+
+	selfmanCmd.cobraCmd.Flags().Bool(
+		doctorCmdOptionFix,
+		false,
+		"Perform operations to fix any problems which can be fixed automatically",
+	)
+
+	return selfmanCmd
+}
+
+func runDoctorCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
+	selfmanData, err := produceSelfmanData(cmd)
+	if err != nil { return nil, err }
+
+	fix, err := cmd.Flags().GetBool(doctorCmdOptionFix)
+	run.AssertNoErr(err)
+
+	findings := diagnoseManagedFiles(selfmanData)
+	findings = append(
+		findings,
+		diagnoseEnvironment(selfmanData.SystemConfig, os.Getenv("PATH"), gitVersionOrEmpty())...,
+	)
+
+	result := &SelfmanResult{
+		textOutput: doctorResult{ findings },
+		operations: nil,
+		systemConfig: selfmanData.SystemConfig,
+	}
+	if fix {
+		for _, finding := range findings {
+			result.operations = append(result.operations, finding.fixOps...)
+		}
+	}
+
+	return result, nil
+}
+
+type doctorFinding struct {
+	problem string
+	// A command the user could run to fix the problem, if there is one
+	suggestion string
+	// Operations which would fix the problem, if it can be fixed automatically
+	fixOps []ops.Operation
+}
+
+type doctorResult struct {
+	findings []doctorFinding
+}
+
+func (self doctorResult) String() string {
+	if len(self.findings) == 0 {
+		return "No problems found"
+	}
+
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("Found %d problem(s):\n", len(self.findings)))
+	for _, finding := range self.findings {
+		buf.WriteString(fmt.Sprintf("\n✗ %s\n", finding.problem))
+		if len(finding.suggestion) > 0 {
+			buf.WriteString(
+				fmt.Sprintf("%ssuggested fix: %s\n", run.IndentChars, finding.suggestion),
+			)
+		}
+	}
+
+	return buf.String()
+}
+
+func gitVersionOrEmpty() string {
+	version, err := git.Version()
+	if err != nil { return "" }
+	return version
+}
+
+// Walks all directories selfman places files in, looking for files which selfman should be
+// managing but which do not match the current configuration.
+func diagnoseManagedFiles(selfmanData data.Selfman) []doctorFinding {
+	system := selfmanData.SystemConfig
+	findings := make([]doctorFinding, 0)
+
+	findings = append(findings, diagnoseLinkDir(
+		*system.BinaryDir,
+		"binary",
+		selfmanData,
+		func(linkName string) (expectedLink, bool) {
+			for _, app := range selfmanData.AppConfigs {
+				for _, artifact := range app.Artifacts() {
+					if artifact.LinkName != linkName { continue }
+					return expectedLink{
+						appName: app.Name,
+						target: artifact.LinkTargetPath,
+						relinkOp: app.GetLinkOp(artifact, ops.ClobberPolicy{}),
+					}, true
+				}
+			}
+			for _, app := range selfmanData.AppConfigs {
+				if !app.LinkVersions { continue }
+				version, isVersionLink := app.VersionedLinkVersion(linkName)
+				if !isVersionLink { continue }
+
+				versionApp := app
+				versionApp.Version = version
+				return expectedLink{
+					appName: app.Name,
+					target: versionApp.EntrypointPath(),
+					relinkOp: versionApp.GetLinkVersionedArtifactOp(ops.ClobberPolicy{}),
+				}, true
+			}
+			return expectedLink{}, false
+		},
+	)...)
+
+	findings = append(findings, diagnoseLinkDir(
+		*system.LibDir,
+		"library",
+		selfmanData,
+		func(linkName string) (expectedLink, bool) {
+			app, configured := selfmanData.AppConfigs[linkName]
+			if !configured || !app.LinkSourceAsLib { return expectedLink{}, false }
+			return expectedLink{
+				appName: app.Name,
+				target: app.SourcePath(),
+				relinkOp: app.GetLinkLibraryOp(ops.ClobberPolicy{}),
+			}, true
+		},
+	)...)
+
+	artifactEntries, _ := os.ReadDir(system.ArtifactsPath())
+	for _, entry := range artifactEntries {
+		if artifactIsConfigured(entry.Name(), selfmanData) { continue }
+
+		artifactPath := path.Join(system.ArtifactsPath(), entry.Name())
+		finding := doctorFinding{
+			problem: fmt.Sprintf("Artifact for an app with no configuration: %s", artifactPath),
+			suggestion: fmt.Sprintf("rm %s", artifactPath),
+			fixOps: []ops.Operation{
+				ops.DeleteFile{
+					TypeOfDeletion: "Delete unconfigured artifact",
+					Path: artifactPath,
+				},
+			},
+		}
+		// apps with multiple build targets have a dir of artifacts per version
+		if entry.IsDir() {
+			finding.suggestion = fmt.Sprintf("rm -r %s", artifactPath)
+			finding.fixOps = []ops.Operation{
+				ops.DeleteDir{
+					TypeOfDeletion: "Delete unconfigured artifacts",
+					Path: artifactPath,
+				},
+			}
+		}
+		findings = append(findings, finding)
+	}
+
+	sourceEntries, _ := os.ReadDir(system.SourcesPath())
+	for _, entry := range sourceEntries {
+		if !entry.IsDir() { continue }
+		if _, configured := selfmanData.AppConfigs[entry.Name()]; configured { continue }
+
+		sourcePath := path.Join(system.SourcesPath(), entry.Name())
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf(
+				"Source directory for an app with no configuration: %s",
+				sourcePath,
+			),
+			suggestion: fmt.Sprintf("rm -r %s", sourcePath),
+			fixOps: []ops.Operation{
+				ops.DeleteDir{
+					TypeOfDeletion: "Delete unconfigured source directory",
+					Path: sourcePath,
+				},
+			},
+		})
+	}
+
+	return findings
+}
+
+// What a link in one of selfman's link directories should look like, according to the config.
+type expectedLink struct {
+	appName string
+	target string
+	relinkOp ops.Operation
+}
+
+// Inspects every symlink (or wrapper script) in the given directory which points into selfman's
+// data dir.
+//
+// expectedLinkFor returns what the link with the given file name should be, or false if no
+// configured app should have a link with that name in this directory.
+func diagnoseLinkDir(
+	dirPath string,
+	linkKind string,
+	selfmanData data.Selfman,
+	expectedLinkFor func(linkName string) (expectedLink, bool),
+) []doctorFinding {
+	findings := make([]doctorFinding, 0)
+	managedDir := selfmanData.SystemConfig.ManagedDir()
+
+	entries, _ := os.ReadDir(dirPath)
+	for _, entry := range entries {
+		linkPath := path.Join(dirPath, entry.Name())
+		target, managed := ops.ManagedLinkTarget(linkPath, managedDir)
+		if !managed { continue }
+
+		deleteLink := []ops.Operation{
+			ops.DeleteFile{
+				TypeOfDeletion: fmt.Sprintf("Delete stray %s link", linkKind),
+				Path: linkPath,
+			},
+		}
+
+		_, err := os.Stat(target)
+		if err != nil && ops.IsRecordedCopy(linkPath, managedDir) {
+			// the copy still works on its own, so it is left for the user to decide about
+			findings = append(findings, doctorFinding{
+				problem: fmt.Sprintf(
+					"%s copy of an artifact which no longer exists: %s (copied from %s)",
+					capitalize(linkKind), linkPath, target,
+				),
+				suggestion: fmt.Sprintf("rebuild the app, or rm %s", linkPath),
+			})
+			continue
+		}
+		if err != nil {
+			findings = append(findings, doctorFinding{
+				problem: fmt.Sprintf(
+					"Dangling %s link into selfman's data: %s -> %s",
+					linkKind, linkPath, target,
+				),
+				suggestion: fmt.Sprintf("rm %s", linkPath),
+				fixOps: deleteLink,
+			})
+			continue
+		}
+
+		expected, configured := expectedLinkFor(entry.Name())
+		if !configured {
+			findings = append(findings, doctorFinding{
+				problem: fmt.Sprintf(
+					"%s link for an app with no configuration: %s -> %s",
+					capitalize(linkKind), linkPath, target,
+				),
+				suggestion: fmt.Sprintf("rm %s", linkPath),
+				fixOps: deleteLink,
+			})
+			continue
+		}
+
+		if filepath.Clean(target) != filepath.Clean(expected.target) {
+			finding := doctorFinding{
+				problem: fmt.Sprintf(
+					"%s link for app %s points to %s instead of the configured %s",
+					capitalize(linkKind), expected.appName, target, expected.target,
+				),
+				suggestion: fmt.Sprintf("selfman make-it-so %s", expected.appName),
+			}
+			// if the configured version hasn't been built yet, relinking would only break things
+			if _, err := os.Stat(expected.target); err == nil {
+				finding.fixOps = []ops.Operation{ expected.relinkOp }
+			}
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
+
+func artifactIsConfigured(artifactName string, selfmanData data.Selfman) bool {
+	for _, app := range selfmanData.AppConfigs {
+		if strings.HasPrefix(artifactName, app.ArtifactFilePrefix()) { return true }
+	}
+	return false
+}
+
+func capitalize(str string) string {
+	if len(str) == 0 { return str }
+	return strings.ToUpper(str[:1]) + str[1:]
+}
+
+// Checks the parts of the environment selfman depends on. None of these problems can be fixed
+// automatically.
+func diagnoseEnvironment(
+	system *data.SystemConfig,
+	pathEnv string,
+	gitVersion string,
+) []doctorFinding {
+	findings := make([]doctorFinding, 0)
+
+	binDirOnPath := false
+	for _, pathDir := range filepath.SplitList(pathEnv) {
+		if len(pathDir) > 0 && filepath.Clean(pathDir) == filepath.Clean(*system.BinaryDir) {
+			binDirOnPath = true
+			break
+		}
+	}
+	if !binDirOnPath {
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf("Binary dir is not on PATH: %s", *system.BinaryDir),
+			suggestion: fmt.Sprintf(
+				"export PATH=\"%s:$PATH\" (in your shell profile)",
+				*system.BinaryDir,
+			),
+		})
+	}
+
+	if _, err := exec.LookPath(*system.ScriptShell); err != nil {
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf("Configured script shell was not found: %s", *system.ScriptShell),
+			suggestion: "set script-shell in selfman's config file to an installed shell",
+		})
+	}
+
+	minimumGitVersion := fmt.Sprintf("%d.%d", git.MinimumMajorVersion, git.MinimumMinorVersion)
+	if len(gitVersion) == 0 {
+		findings = append(findings, doctorFinding{
+			problem: "Could not find a working git executable on PATH",
+			suggestion: fmt.Sprintf("install git (version %s or newer)", minimumGitVersion),
+		})
+	} else if supported, err := git.VersionIsSupported(gitVersion); err != nil || !supported {
+		findings = append(findings, doctorFinding{
+			problem: fmt.Sprintf(
+				"git version %s is older than the minimum supported version %s",
+				gitVersion, minimumGitVersion,
+			),
+			suggestion: "upgrade git",
+		})
+	}
+
+	return findings
+}
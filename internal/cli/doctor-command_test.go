@@ -0,0 +1,324 @@
+package cli
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/data/mocks"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a system config whose directories all exist inside a fresh temp dir.
+func tempDirTestConfig(t *testing.T) *data.SystemConfig {
+	rootDir := t.TempDir()
+	systemConfig := &data.SystemConfig{
+		AppConfigDir: run.StrPtr(path.Join(rootDir, "apps")),
+		DataDir: run.StrPtr(path.Join(rootDir, "data")),
+		BinaryDir: run.StrPtr(path.Join(rootDir, "bin")),
+		LibDir: run.StrPtr(path.Join(rootDir, "lib")),
+		ShareDir: run.StrPtr(path.Join(rootDir, "share")),
+		ServiceDir: run.StrPtr(path.Join(rootDir, "systemd")),
+		ScriptShell: run.StrPtr("/bin/sh"),
+	}
+	for _, dirPath := range []string{
+		*systemConfig.AppConfigDir,
+		*systemConfig.BinaryDir,
+		*systemConfig.LibDir,
+		systemConfig.ArtifactsPath(),
+		systemConfig.SourcesPath(),
+	} {
+		run.AssertNoErr(os.MkdirAll(dirPath, 0o755))
+	}
+	return systemConfig
+}
+
+func touchFile(filePath string) {
+	run.AssertNoErr(os.MkdirAll(path.Dir(filePath), 0o755))
+	run.AssertNoErr(os.WriteFile(filePath, []byte("test file"), 0o755))
+}
+
+func TestDoctorFindsStrayManagedFiles(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	configuredApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "configured",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ configuredApp },
+		&mocks.MockManagedFiles{},
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	// configured app, but linked to an old artifact
+	touchFile(configuredApp.ArtifactPath())
+	oldArtifactPath := path.Join(systemConfig.ArtifactsPath(), "configured---old")
+	touchFile(oldArtifactPath)
+	run.AssertNoErr(os.Symlink(oldArtifactPath, configuredApp.BinaryPath()))
+	touchFile(path.Join(configuredApp.SourcePath(), "README"))
+
+	// files for an app which is no longer configured
+	goneArtifactPath := path.Join(systemConfig.ArtifactsPath(), "gone---1.0")
+	touchFile(goneArtifactPath)
+	goneSourcePath := path.Join(systemConfig.SourcesPath(), "gone")
+	touchFile(path.Join(goneSourcePath, "1.0", "README"))
+	goneLinkPath := path.Join(*systemConfig.BinaryDir, "gone")
+	run.AssertNoErr(os.Symlink(goneArtifactPath, goneLinkPath))
+
+	// dangling link into selfman's data
+	danglingLinkPath := path.Join(*systemConfig.LibDir, "dangling")
+	run.AssertNoErr(
+		os.Symlink(path.Join(systemConfig.SourcesPath(), "missing"), danglingLinkPath),
+	)
+
+	// files which selfman does not manage should be left alone
+	run.AssertNoErr(os.Symlink("/bin/sh", path.Join(*systemConfig.BinaryDir, "not-selfman")))
+	touchFile(path.Join(*systemConfig.BinaryDir, "regular-file"))
+
+	findings := diagnoseManagedFiles(selfmanData)
+
+	fixOps := make([]ops.Operation, 0)
+	for _, finding := range findings {
+		fixOps = append(fixOps, finding.fixOps...)
+	}
+
+	expectedOps := []ops.Operation{
+		ops.LinkArtifact{
+			SourcePath: configuredApp.ArtifactPath(),
+			DestinationPath: configuredApp.BinaryPath(),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		ops.DeleteFile{
+			TypeOfDeletion: "Delete stray binary link",
+			Path: goneLinkPath,
+		},
+		ops.DeleteFile{
+			TypeOfDeletion: "Delete stray library link",
+			Path: danglingLinkPath,
+		},
+		ops.DeleteFile{
+			TypeOfDeletion: "Delete unconfigured artifact",
+			Path: goneArtifactPath,
+		},
+		ops.DeleteDir{
+			TypeOfDeletion: "Delete unconfigured source directory",
+			Path: goneSourcePath,
+		},
+	}
+	assert.Equal(t, expectedOps, fixOps)
+	assert.Len(t, findings, len(expectedOps))
+}
+
+func TestTopLevelLeavesProfileLinksAlone(t *testing.T) {
+	rootDir := t.TempDir()
+	configPath := path.Join(rootDir, "config.yaml")
+	run.AssertNoErr(os.WriteFile(
+		configPath,
+		[]byte(
+			"data-dir: " + path.Join(rootDir, "data") + "\n" +
+			"app-config-dir: " + path.Join(rootDir, "apps") + "\n" +
+			"binary-dir: " + path.Join(rootDir, "bin") + "\n" +
+			"profiles:\n" +
+			"  work: {}\n",
+		),
+		0o644,
+	))
+	loadConfig := func(profile string) *data.SystemConfig {
+		overrides := data.ConfigOverrides{ ConfigPath: configPath, Profile: &profile }
+		systemConfig, _, err := data.LoadSystemConfig(overrides)
+		run.AssertNoErr(err)
+		return &systemConfig
+	}
+	topConfig := loadConfig("")
+	workConfig := loadConfig("work")
+
+	newApp := func(systemConfig *data.SystemConfig) data.AppConfig {
+		return data.AppConfig{
+			SystemConfig: systemConfig,
+			Name: "tool",
+			Flavor: data.FlavorGit,
+			RemoteRepo: run.StrPtr("doesn't matter"),
+			BuildAction: data.ActionNone,
+			Version: "v1",
+		}
+	}
+	workApp := newApp(workConfig)
+	touchFile(workApp.ArtifactPath())
+	for _, action := range workApp.GetLinkArtifactOps(ops.ClobberPolicy{}) {
+		_, err := action.Execute(t.Context())
+		run.AssertNoErr(err)
+	}
+
+	// the profile's link is not reported (or fixed) by the top-level doctor
+	topData, err := data.SelfmanFromValues(topConfig, nil, &mocks.MockManagedFiles{})
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Empty(t, diagnoseManagedFiles(topData))
+
+	// nor replaced by a top-level app of the same name without --force
+	topApp := newApp(topConfig)
+	touchFile(topApp.ArtifactPath())
+	status := (&data.AppManagedFiles{
+		AppConfigs: map[string]data.AppConfig{ topApp.Name: topApp },
+	}).AppStatus(topApp.Name)
+	assert.True(t, status.LinkIsForeign)
+	for _, action := range topApp.GetLinkArtifactOps(ops.ClobberPolicy{}) {
+		_, err := action.Execute(t.Context())
+		assert.ErrorContains(t, err, "Refusing to replace")
+	}
+	target, err := os.Readlink(workApp.BinaryPath())
+	assert.NoError(t, err)
+	assert.Equal(t, workApp.ArtifactPath(), target)
+}
+
+func TestDoctorKeepsCopiesOfDeletedArtifacts(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	copiedApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "copied",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+		LinkMode: data.LinkModeCopy,
+	}
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ copiedApp },
+		&mocks.MockManagedFiles{},
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	copiedApp = selfmanData.AppConfigs[copiedApp.Name]
+
+	touchFile(copiedApp.ArtifactPath())
+	for _, action := range copiedApp.GetLinkArtifactOps(ops.ClobberPolicy{}) {
+		_, err := action.Execute(t.Context())
+		run.AssertNoErr(err)
+	}
+	run.AssertNoErr(os.Remove(copiedApp.ArtifactPath()))
+
+	findings := diagnoseManagedFiles(selfmanData)
+	assert.Len(t, findings, 1)
+	run.BailIfFailed(t)
+	assert.Contains(t, findings[0].problem, "copy of an artifact which no longer exists")
+	assert.Empty(t, findings[0].fixOps, "The copy still works, so it is not deleted")
+}
+
+func TestDoctorChecksVersionedLinks(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	versionedApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "versioned",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "v2",
+		LinkVersions: true,
+	}
+	unversionedApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "unversioned",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "v1",
+	}
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ versionedApp, unversionedApp },
+		&mocks.MockManagedFiles{},
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	versionedApp = selfmanData.AppConfigs[versionedApp.Name]
+	unversionedApp = selfmanData.AppConfigs[unversionedApp.Name]
+
+	// a correct versioned link for a version other than the configured one
+	oldVersionApp := versionedApp
+	oldVersionApp.Version = "v1"
+	touchFile(oldVersionApp.ArtifactPath())
+	run.AssertNoErr(os.Symlink(oldVersionApp.ArtifactPath(), versionedApp.VersionedBinaryPath("v1")))
+
+	// a versioned link left over from before an app stopped linking versions
+	touchFile(unversionedApp.ArtifactPath())
+	leftoverLinkPath := unversionedApp.VersionedBinaryPath("v1")
+	run.AssertNoErr(os.Symlink(unversionedApp.ArtifactPath(), leftoverLinkPath))
+
+	findings := diagnoseManagedFiles(selfmanData)
+
+	assert.Len(t, findings, 1)
+	run.BailIfFailed(t)
+	assert.Equal(
+		t,
+		[]ops.Operation{
+			ops.DeleteFile{
+				TypeOfDeletion: "Delete stray binary link",
+				Path: leftoverLinkPath,
+			},
+		},
+		findings[0].fixOps,
+	)
+}
+
+func TestDoctorDoesNotRelinkToUnbuiltArtifact(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	configuredApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "configured",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "new-version",
+	}
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ configuredApp },
+		&mocks.MockManagedFiles{},
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	oldArtifactPath := path.Join(systemConfig.ArtifactsPath(), "configured---old-version")
+	touchFile(oldArtifactPath)
+	run.AssertNoErr(os.Symlink(oldArtifactPath, configuredApp.BinaryPath()))
+
+	findings := diagnoseManagedFiles(selfmanData)
+
+	assert.Len(t, findings, 1)
+	run.BailIfFailed(t)
+	assert.Equal(t, "selfman make-it-so configured", findings[0].suggestion)
+	assert.Empty(t, findings[0].fixOps)
+}
+
+func TestDoctorChecksEnvironment(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+	systemConfig.ScriptShell = run.StrPtr("/not/a/real/shell")
+
+	findings := diagnoseEnvironment(systemConfig, "/usr/bin:/bin", "2.20.1")
+	assert.Len(t, findings, 3)
+
+	findings = diagnoseEnvironment(
+		data.DefaultTestConfig(),
+		"/usr/bin:" + *systemConfig.BinaryDir + "/",
+		"2.43.0.windows.1",
+	)
+	assert.Empty(t, findings)
+}
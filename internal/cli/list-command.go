@@ -6,11 +6,14 @@ import (
 	"strings"
 
 	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/run"
 	"github.com/spf13/cobra"
 )
 
+const listCmdOptionAllProfiles = "all-profiles"
+
 func CreateListCmd() SelfmanCommand {
-	return SelfmanCommand{
+	selfmanCmd := SelfmanCommand{
 		cobraCmd: &cobra.Command{
 			Use: "list",
 			Short: "List all applications managed by selfman",
@@ -18,11 +21,31 @@ func CreateListCmd() SelfmanCommand {
 		},
 		runFunc: runListCmd,
 	}
+
+	selfmanCmd.cobraCmd.Flags().Bool(
+		listCmdOptionAllProfiles,
+		false,
+		"List applications for every configured profile (as well as for no profile)",
+	)
+
+	return selfmanCmd
 }
 
 func runListCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 	if err := validatePrereqs(); err != nil { return nil, err }
-	configData, err := data.Produce()
+
+	allProfiles, err := cmd.Flags().GetBool(listCmdOptionAllProfiles)
+	run.AssertNoErr(err)
+	if allProfiles {
+		result, err := listAllProfiles(configOverridesFromFlags(cmd))
+		if err != nil { return nil, err }
+		return &SelfmanResult{
+			textOutput: result,
+			operations: nil,
+		}, nil
+	}
+
+	configData, err := produceSelfmanData(cmd)
 	if err != nil { return nil, err }
 
 	results := listApplications(configData)
@@ -32,6 +55,58 @@ func runListCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 	}, nil
 }
 
+func listAllProfiles(overrides data.ConfigOverrides) (allProfilesListResult, error) {
+	profileNames, err := data.ProfileNames(overrides)
+	if err != nil { return allProfilesListResult{}, err }
+
+	// the empty profile name selects the top-level (non-profile) settings
+	profileNames = append([]string{ "" }, profileNames...)
+	result := allProfilesListResult{}
+	for _, profileName := range profileNames {
+		overrides.Profile = &profileName
+		selfmanData, err := data.Produce(overrides)
+		if err != nil { return allProfilesListResult{}, err }
+
+		result.profiles = append(result.profiles, profileListResult{
+			profileName: profileName,
+			apps: listCmdResult{ listApplications(selfmanData) },
+		})
+	}
+
+	return result, nil
+}
+
+type allProfilesListResult struct {
+	profiles []profileListResult
+}
+
+type profileListResult struct {
+	profileName string
+	apps listCmdResult
+}
+
+func (self allProfilesListResult) String() string {
+	var buf strings.Builder
+	for i, profile := range self.profiles {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+
+		profileLabel := profile.profileName
+		if len(profileLabel) == 0 {
+			profileLabel = "(no profile)"
+		}
+		buf.WriteString(fmt.Sprintf("[%s]\n", profileLabel))
+
+		if len(profile.apps.results) == 0 {
+			buf.WriteString("no applications configured\n")
+		}
+		buf.WriteString(profile.apps.String())
+	}
+
+	return buf.String()
+}
+
 type listCmdResult struct {
 	results []listResult
 }
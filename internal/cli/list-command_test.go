@@ -1,6 +1,10 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/lorentzforces/selfman/internal/data"
@@ -147,3 +151,60 @@ func TestAppsAreSortedInLexicalOrder(t *testing.T) {
 	}
 	assert.Equal(t, expected, results)
 }
+
+func TestListAllProfilesListsEachProfilesApps(t *testing.T) {
+	rootDir := t.TempDir()
+	configPath := path.Join(rootDir, "config.yaml")
+	profileSettings := func(name string) string {
+		return fmt.Sprintf(
+			"    app-config-dir: %[1]s/%[2]s/apps\n" +
+				"    data-dir: %[1]s/%[2]s/data\n" +
+				"    binary-dir: %[1]s/%[2]s/bin\n" +
+				"    lib-dir: %[1]s/%[2]s/lib\n",
+			rootDir, name,
+		)
+	}
+	err := os.WriteFile(
+		configPath,
+		[]byte(
+			strings.ReplaceAll(profileSettings("default"), "    ", "") +
+			"profiles:\n" +
+			"  work:\n" + profileSettings("work") +
+			"  personal:\n" + profileSettings("personal"),
+		),
+		0o644,
+	)
+	run.AssertNoErr(err)
+
+	writeApp := func(profile string, appName string) {
+		appDir := path.Join(rootDir, profile, "apps")
+		run.AssertNoErr(os.MkdirAll(appDir, 0o755))
+		err := os.WriteFile(
+			path.Join(appDir, appName + ".config.yaml"),
+			[]byte(fmt.Sprintf(
+				"name: %s\nflavor: web-fetch\nweb-url: https://example.com\nbuild-action: none\n",
+				appName,
+			)),
+			0o644,
+		)
+		run.AssertNoErr(err)
+	}
+	writeApp("default", "default-app")
+	writeApp("work", "work-app")
+	writeApp("personal", "personal-app")
+
+	result, err := listAllProfiles(data.ConfigOverrides{ ConfigPath: configPath })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	profileNames := make([]string, 0, len(result.profiles))
+	appNames := make([]string, 0, len(result.profiles))
+	for _, profile := range result.profiles {
+		profileNames = append(profileNames, profile.profileName)
+		for _, app := range profile.apps.results {
+			appNames = append(appNames, app.name)
+		}
+	}
+	assert.Equal(t, []string{ "", "personal", "work" }, profileNames)
+	assert.Equal(t, []string{ "default-app", "personal-app", "work-app" }, appNames)
+}
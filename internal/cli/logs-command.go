@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/spf13/cobra"
+)
+
+const (
+	logsCmdOptionLast = "last"
+	logsCmdOptionList = "list"
+)
+
+func CreateLogsCmd() SelfmanCommand {
+	selfmanCmd := SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "logs [flags] app-name",
+			Short: "Show logged output from an application's builds and git operations",
+		},
+		runFunc: runLogsCmd,
+	}
+
+	selfmanCmd.cobraCmd.Flags().Bool(
+		logsCmdOptionLast,
+		false,
+		"Print the most recent log file (the default)",
+	)
+	selfmanCmd.cobraCmd.Flags().Bool(
+		logsCmdOptionList,
+		false,
+		"List the application's log files, oldest first, instead of printing one",
+	)
+	selfmanCmd.cobraCmd.MarkFlagsMutuallyExclusive(logsCmdOptionLast, logsCmdOptionList)
+
+	return selfmanCmd
+}
+
+func runLogsCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
+	selfmanData, err := produceSelfmanData(cmd)
+	if err != nil { return nil, err }
+
+	if len(args) < 1 {
+		return nil,
+			fmt.Errorf("Logs command expects an application name, but one was not provided")
+	}
+	list, err := cmd.Flags().GetBool(logsCmdOptionList)
+	run.AssertNoErr(err)
+
+	result, err := appLogs(args[0], list, selfmanData)
+	if err != nil { return nil, err }
+
+	return &SelfmanResult{
+		textOutput: result,
+		operations: nil,
+	}, nil
+}
+
+type logsResult struct {
+	appName string
+	logsDir string
+	// Oldest first
+	logFiles []string
+	// Only set when showing a single log file
+	contents *string
+}
+
+func appLogs(name string, list bool, selfmanData data.Selfman) (logsResult, error) {
+	app, present := selfmanData.AppConfigs[name]
+	if !present {
+		return logsResult{}, fmt.Errorf("No configuration for an app named %s was found", name)
+	}
+
+	result := logsResult{ appName: name, logsDir: app.LogsPath() }
+	entries, err := os.ReadDir(result.logsDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return logsResult{}, fmt.Errorf("Error reading log dir for app %s: %w", name, err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".log") {
+			result.logFiles = append(result.logFiles, entry.Name())
+		}
+	}
+	// log file names start with a timestamp, so name order is age order
+	slices.Sort(result.logFiles)
+
+	if list || len(result.logFiles) == 0 { return result, nil }
+
+	lastLog := path.Join(result.logsDir, result.logFiles[len(result.logFiles) - 1])
+	contents, err := os.ReadFile(lastLog)
+	if err != nil { return logsResult{}, fmt.Errorf("Error reading log file: %w", err) }
+	result.contents = run.StrPtr(string(contents))
+	return result, nil
+}
+
+func (self logsResult) String() string {
+	if len(self.logFiles) == 0 {
+		return fmt.Sprintf("No logs found for %s\n", self.appName)
+	}
+
+	if self.contents != nil {
+		lastLog := path.Join(self.logsDir, self.logFiles[len(self.logFiles) - 1])
+		return fmt.Sprintf("📋 %s\n\n%s", lastLog, *self.contents)
+	}
+
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf("📋 %s logs (in %s)\n", self.appName, self.logsDir))
+	for _, logFile := range self.logFiles {
+		buf.WriteString(run.IndentChars + logFile + "\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/data/mocks"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogsShowsMostRecentLogOrListsAll(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "chatty",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ app },
+		&mocks.MockManagedFiles{},
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	result, err := appLogs(app.Name, false, selfmanData)
+	assert.NoError(t, err)
+	assert.Equal(t, "No logs found for chatty\n", result.String())
+
+	for _, logFile := range []string{
+		"2026-01-02T10-00-00.000-git-fetch.log",
+		"2026-01-02T10-00-01.000-build.log",
+		"2026-01-01T09-00-00.000-build.log",
+	} {
+		contents := []byte("output of " + logFile + "\n")
+		run.AssertNoErr(os.MkdirAll(app.LogsPath(), 0o755))
+		run.AssertNoErr(os.WriteFile(path.Join(app.LogsPath(), logFile), contents, 0o644))
+	}
+
+	result, err = appLogs(app.Name, false, selfmanData)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"📋 " + path.Join(app.LogsPath(), "2026-01-02T10-00-01.000-build.log") + "\n\n" +
+			"output of 2026-01-02T10-00-01.000-build.log\n",
+		result.String(),
+	)
+
+	result, err = appLogs(app.Name, true, selfmanData)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"📋 chatty logs (in " + app.LogsPath() + ")\n" +
+			"    2026-01-01T09-00-00.000-build.log\n" +
+			"    2026-01-02T10-00-00.000-git-fetch.log\n" +
+			"    2026-01-02T10-00-01.000-build.log\n",
+		result.String(),
+	)
+
+	_, err = appLogs("unconfigured", false, selfmanData)
+	assert.Error(t, err)
+}
+
+func TestLogsFlagsAreMutuallyExclusive(t *testing.T) {
+	for _, flags := range [][]string{ { "--last" }, { "--list" } } {
+		logsCmd := CreateLogsCmd().cobraCmd
+		assert.NoError(t, logsCmd.ParseFlags(flags))
+		assert.NoError(t, logsCmd.ValidateFlagGroups())
+	}
+
+	logsCmd := CreateLogsCmd().cobraCmd
+	assert.NoError(t, logsCmd.ParseFlags([]string{ "--last", "--list" }))
+	assert.ErrorContains(t, logsCmd.ValidateFlagGroups(), "none of the others can be")
+}
@@ -9,7 +9,7 @@ import (
 )
 
 func CreateMakeItSoCmd() SelfmanCommand {
-	return SelfmanCommand{
+	selfmanCmd := SelfmanCommand{
 		cobraCmd: &cobra.Command{
 			Use: "make-it-so",
 			Short: "Update, install, or otherwise make an application up-to-date with its " +
@@ -18,11 +18,15 @@ func CreateMakeItSoCmd() SelfmanCommand {
 		},
 		runFunc: runMakeItSoCmd,
 	}
+
+	addClobberFlags(selfmanCmd.cobraCmd)
+
+	return selfmanCmd
 }
 
 func runMakeItSoCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 	if err := validatePrereqs(); err != nil { return nil, err }
-	selfmanData, err := data.Produce()
+	selfmanData, err := produceSelfmanData(cmd)
 	if err != nil { return nil, err }
 
 	if len(args) < 1 {
@@ -32,24 +36,32 @@ func runMakeItSoCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 		}
 	}
 
-	ops, err := makeItSo(args[0], selfmanData)
+	clobber := clobberPolicyFromFlags(cmd, selfmanData.SystemConfig)
+	ops, err := makeItSo(args[0], clobber, selfmanData)
 	if err != nil { return nil, err }
 
+	app, appStatus := selfmanData.AppStatus(args[0])
 	return &SelfmanResult{
 		textOutput: nil,
+		followUpOutput: serviceCommandsAfterInstall(app, appStatus),
 		operations: ops,
+		systemConfig: selfmanData.SystemConfig,
 	}, nil
 }
 
-func makeItSo(name string, selfmanData data.Selfman) ([]ops.Operation, error) {
+func makeItSo(
+	name string,
+	clobber ops.ClobberPolicy,
+	selfmanData data.Selfman,
+) ([]ops.Operation, error) {
 	app, appStatus := selfmanData.AppStatus(name)
 	if !appStatus.IsConfigured {
 		return nil, fmt.Errorf("Could not find a configured application with name \"%s\"", name)
 	}
 
-	buildTargetPath := app.BuildTargetPath()
-	artifactPath := app.ArtifactPath()
-	binPath := app.BinaryPath()
+	if !app.CanObtainSource() && !appStatus.TargetPresent {
+		return nil, unobtainableArtifactError(app)
+	}
 
 	actions := make([]ops.Operation, 0, 10)
 
@@ -68,55 +80,63 @@ func makeItSo(name string, selfmanData data.Selfman) ([]ops.Operation, error) {
 		actions = append(actions, versionOp)
 	}
 
-	moveBinAction := ops.MoveTarget{
-		SourcePath: buildTargetPath,
-		DestinationPath: artifactPath,
-	}
 	if !appStatus.TargetPresent {
+		actions = append(actions, app.GetHookOps(data.HookPreBuild)...)
 		actions = append(actions, app.GetBuildOp())
 		if !app.KeepBinWithSource {
-			actions = append(
-				actions,
-				ops.MoveTarget{
-					SourcePath: buildTargetPath,
-					DestinationPath: artifactPath,
-				},
-			)
+			actions = append(actions, app.GetMoveTargetOps()...)
 		}
+		actions = append(actions, app.GetHookOps(data.HookPostBuild)...)
+		actions = append(actions, app.GetStoreExtraFileOps()...)
 	} else if app.Flavor == data.FlavorGit && appStatus.TargetPresent {
 		commitChangeOp := ops.MetaOpCommitChanged{
 			RepoPath: app.SourcePath(),
 			OrigCommitHash: appStatus.CurrentCommitHash,
-			IfChangedOps: []ops.Operation{ app.GetBuildOp() },
+			IfChangedOps: append(app.GetHookOps(data.HookPreBuild), app.GetBuildOp()),
 		}
 
 		if !app.KeepBinWithSource {
 			commitChangeOp.IfChangedOps = append(
 				commitChangeOp.IfChangedOps,
-				moveBinAction,
+				app.GetMoveTargetOps()...,
+			)
+		}
+		commitChangeOp.IfChangedOps = append(
+			commitChangeOp.IfChangedOps,
+			app.GetHookOps(data.HookPostBuild)...,
+		)
+		// missing extra files are stored below regardless of whether the commit changed
+		if appStatus.ExtraFilesStored {
+			commitChangeOp.IfChangedOps = append(
+				commitChangeOp.IfChangedOps,
+				app.GetStoreExtraFileOps()...,
 			)
 		}
 
 		actions = append(actions, commitChangeOp)
 	}
 
-	actions = append(
-		actions,
-		ops.LinkArtifact{
-			SourcePath: artifactPath,
-			DestinationPath: binPath,
-		},
-	)
+	if appStatus.TargetPresent && !appStatus.ExtraFilesStored {
+		actions = append(actions, app.GetStoreExtraFileOps()...)
+	}
+
+	actions = append(actions, app.GetLinkArtifactOps(clobber)...)
+	if app.LinkVersions {
+		actions = append(actions, app.GetLinkVersionedArtifactOp(clobber))
+	}
 
 	if app.LinkSourceAsLib {
-		actions = append(
-			actions,
-			ops.LinkLibrary{
-				SourcePath: app.SourcePath(),
-				DestinationPath: app.LibPath(),
-			},
-		)
+		actions = append(actions, app.GetLinkLibraryOp(clobber))
+	}
+
+	actions = append(actions, app.GetLinkExtraFileOps(clobber)...)
+	if app.DesktopEntry != nil {
+		actions = append(actions, app.GetWriteDesktopEntryOp(clobber))
+	}
+	if app.Service != nil {
+		actions = append(actions, app.GetWriteServiceUnitOp(clobber))
 	}
+	actions = append(actions, app.GetHookOps(data.HookPostLink)...)
 
 	return actions, nil
 }
@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"os"
 	"path"
+	"slices"
 	"testing"
 
 	"github.com/lorentzforces/selfman/internal/data"
@@ -19,7 +21,7 @@ func TestMakeItSoFailsWithNonConfiguredApp(t *testing.T) {
 		Storage: nil,
 	}
 
-	_, err := makeItSo("not-available-name", selfmanData)
+	_, err := makeItSo("not-available-name", ops.ClobberPolicy{}, selfmanData)
 	assert.Error(
 		t, err,
 		"An error must be thrown if the user attempts to update an application which is not " +
@@ -53,7 +55,7 @@ func TestMakeItSoInstallGitAppFromScratch(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(appToInstall.Name, selfmanData)
+	actions, err := makeItSo(appToInstall.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
@@ -61,15 +63,22 @@ func TestMakeItSoInstallGitAppFromScratch(t *testing.T) {
 		ops.GitClone{
 			RepoUrl: *selfmanData.AppConfigs[appToInstall.Name].RemoteRepo,
 			DestinationPath: appToInstall.SourcePath(),
+			Network: appToInstall.NetworkPolicy(),
+			LogDir: appToInstall.LogsPath(),
+			AppName: appToInstall.Name,
 		},
 		ops.GitCheckoutRef{
 			RepoPath: appToInstall.SourcePath(),
 			RefName: appToInstall.Version,
+			LogDir: appToInstall.LogsPath(),
+			AppName: appToInstall.Name,
 		},
 		ops.BuildWithScript{
 			SourcePath: appToInstall.SourcePath(),
 			ScriptShell: "/bin/sh",
 			ScriptCmd: "make build",
+			LogDir: appToInstall.LogsPath(),
+			AppName: appToInstall.Name,
 		},
 		ops.MoveTarget{
 			SourcePath: path.Join(appToInstall.SourcePath(), appToInstall.Name),
@@ -78,6 +87,7 @@ func TestMakeItSoInstallGitAppFromScratch(t *testing.T) {
 		ops.LinkArtifact{
 			SourcePath: appToInstall.ArtifactPath(),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, appToInstall.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
@@ -109,7 +119,7 @@ func TestMakeItSoInstallWebFetchAppFromScratch(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(appToInstall.Name, selfmanData)
+	actions, err := makeItSo(appToInstall.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
@@ -118,11 +128,14 @@ func TestMakeItSoInstallWebFetchAppFromScratch(t *testing.T) {
 			SourceUrl: *appToInstall.WebUrl,
 			Version: appToInstall.Version,
 			DestinationDir: appToInstall.SourcePath(),
+			Network: appToInstall.NetworkPolicy(),
 		},
 		ops.BuildWithScript{
 			SourcePath: appToInstall.SourcePath(),
 			ScriptShell: "/bin/sh",
 			ScriptCmd: "tar -xzf web-fetch-app-*.zip",
+			LogDir: appToInstall.LogsPath(),
+			AppName: appToInstall.Name,
 		},
 		ops.MoveTarget{
 			SourcePath: path.Join(appToInstall.SourcePath(), appToInstall.Name),
@@ -131,6 +144,7 @@ func TestMakeItSoInstallWebFetchAppFromScratch(t *testing.T) {
 		ops.LinkArtifact{
 			SourcePath: appToInstall.ArtifactPath(),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, appToInstall.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
@@ -165,17 +179,22 @@ func TestMakeItSoGitWithPresentVersionStillFetches(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(gitApp.Name, selfmanData)
+	actions, err := makeItSo(gitApp.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
 	expectedActions := []ops.Operation{
 		ops.GitFetch{
 			RepoPath: gitApp.SourcePath(),
+			Network: gitApp.NetworkPolicy(),
+			LogDir: gitApp.LogsPath(),
+			AppName: gitApp.Name,
 		},
 		ops.GitCheckoutRef{
 			RepoPath: gitApp.SourcePath(),
 			RefName: gitApp.Version,
+			LogDir: gitApp.LogsPath(),
+			AppName: gitApp.Name,
 		},
 		ops.NoBuildOp,
 		ops.MoveTarget{
@@ -185,6 +204,7 @@ func TestMakeItSoGitWithPresentVersionStillFetches(t *testing.T) {
 		ops.LinkArtifact{
 			SourcePath: gitApp.ArtifactPath(),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, gitApp.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
@@ -221,7 +241,7 @@ func TestMakeItSoWebFetchWithNoChangesDoesNothing(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(unchangedApp.Name, selfmanData)
+	actions, err := makeItSo(unchangedApp.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
@@ -230,6 +250,7 @@ func TestMakeItSoWebFetchWithNoChangesDoesNothing(t *testing.T) {
 		ops.LinkArtifact{
 			SourcePath: path.Join(unchangedApp.ArtifactPath()),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, unchangedApp.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
@@ -264,7 +285,7 @@ func TestMakeItSoWithSourceAndNothingElseDoesntFetch(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(appToInstall.Name, selfmanData)
+	actions, err := makeItSo(appToInstall.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
@@ -273,6 +294,8 @@ func TestMakeItSoWithSourceAndNothingElseDoesntFetch(t *testing.T) {
 			SourcePath: appToInstall.SourcePath(),
 			ScriptShell: "/bin/sh",
 			ScriptCmd: "tar -xzf web-fetch-app-*.zip",
+			LogDir: appToInstall.LogsPath(),
+			AppName: appToInstall.Name,
 		},
 		ops.MoveTarget{
 			SourcePath: path.Join(appToInstall.SourcePath(), appToInstall.Name),
@@ -281,6 +304,7 @@ func TestMakeItSoWithSourceAndNothingElseDoesntFetch(t *testing.T) {
 		ops.LinkArtifact{
 			SourcePath: appToInstall.ArtifactPath(),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, appToInstall.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
@@ -313,7 +337,7 @@ func TestMakeItSoKeepingBinInPlace(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(inPlaceApp.Name, selfmanData)
+	actions, err := makeItSo(inPlaceApp.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
@@ -322,15 +346,19 @@ func TestMakeItSoKeepingBinInPlace(t *testing.T) {
 			SourceUrl: *inPlaceApp.WebUrl,
 			Version: inPlaceApp.Version,
 			DestinationDir: inPlaceApp.SourcePath(),
+			Network: inPlaceApp.NetworkPolicy(),
 		},
 		ops.BuildWithScript{
 			SourcePath: inPlaceApp.SourcePath(),
 			ScriptShell: "/bin/sh",
 			ScriptCmd: *inPlaceApp.BuildCmd,
+			LogDir: inPlaceApp.LogsPath(),
+			AppName: inPlaceApp.Name,
 		},
 		ops.LinkArtifact{
 			SourcePath: path.Join(inPlaceApp.SourcePath(), inPlaceApp.Name),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, inPlaceApp.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
@@ -362,7 +390,7 @@ func TestMakeItSoLinksLibrary(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(libApp.Name, selfmanData)
+	actions, err := makeItSo(libApp.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
@@ -371,6 +399,7 @@ func TestMakeItSoLinksLibrary(t *testing.T) {
 			SourceUrl: *libApp.WebUrl,
 			Version: libApp.Version,
 			DestinationDir: libApp.SourcePath(),
+			Network: libApp.NetworkPolicy(),
 		},
 		ops.NoBuildOp,
 		ops.MoveTarget{
@@ -380,10 +409,12 @@ func TestMakeItSoLinksLibrary(t *testing.T) {
 		ops.LinkArtifact{
 			SourcePath: libApp.ArtifactPath(),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, libApp.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 		ops.LinkLibrary{
 			SourcePath: libApp.SourcePath(),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.LibDir, libApp.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
@@ -421,17 +452,22 @@ func TestMakeItSoGitWithTargetPresentChecksCommit(t *testing.T) {
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
-	actions, err := makeItSo(gitApp.Name, selfmanData)
+	actions, err := makeItSo(gitApp.Name, ops.ClobberPolicy{}, selfmanData)
 	assert.NoError(t, err)
 	run.BailIfFailed(t)
 
 	expectedActions := []ops.Operation{
 		ops.GitFetch{
 			RepoPath: gitApp.SourcePath(),
+			Network: gitApp.NetworkPolicy(),
+			LogDir: gitApp.LogsPath(),
+			AppName: gitApp.Name,
 		},
 		ops.GitCheckoutRef{
 			RepoPath: gitApp.SourcePath(),
 			RefName: gitApp.Version,
+			LogDir: gitApp.LogsPath(),
+			AppName: gitApp.Name,
 		},
 		ops.MetaOpCommitChanged{
 			RepoPath: gitApp.SourcePath(),
@@ -441,6 +477,8 @@ func TestMakeItSoGitWithTargetPresentChecksCommit(t *testing.T) {
 					SourcePath: gitApp.SourcePath(),
 					ScriptShell: "/bin/sh",
 					ScriptCmd: *gitApp.BuildCmd,
+					LogDir: gitApp.LogsPath(),
+					AppName: gitApp.Name,
 				},
 				ops.MoveTarget{
 					SourcePath: path.Join(gitApp.SourcePath(), gitApp.Name),
@@ -451,7 +489,797 @@ func TestMakeItSoGitWithTargetPresentChecksCommit(t *testing.T) {
 		ops.LinkArtifact{
 			SourcePath: gitApp.ArtifactPath(),
 			DestinationPath: path.Join(*selfmanData.SystemConfig.BinaryDir, gitApp.Name),
+			ManagedDir: selfmanData.SystemConfig.ManagedDir(),
 		},
 	}
 	assert.Equal(t, expectedActions, actions)
 }
+
+func TestMakeItSoLinksVersionSideBySide(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	versionedApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "versioned-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "origin/main",
+		LinkVersions: true,
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", versionedApp.Name).Return(data.AppStatus{
+		IsConfigured: true,
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ versionedApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	actions, err := makeItSo(versionedApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	artifactPath := path.Join(systemConfig.ArtifactsPath(), "versioned-app---origin%SLASH%main")
+	expectedLinks := []ops.Operation{
+		ops.LinkArtifact{
+			SourcePath: artifactPath,
+			DestinationPath: path.Join(*systemConfig.BinaryDir, "versioned-app"),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		ops.LinkArtifact{
+			SourcePath: artifactPath,
+			DestinationPath: path.Join(*systemConfig.BinaryDir, "versioned-app@origin%SLASH%main"),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+	}
+	assert.Equal(t, expectedLinks, actions[len(actions) - 2:])
+}
+
+func TestMakeItSoWritesWrapperScript(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	wrappedApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "wrapped-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+		Wrapper: &data.WrapperConfig{
+			Env: map[string]string{ "APP_MODE": "fancy" },
+			RunInSourceDir: true,
+			Args: []string{ "--verbose" },
+		},
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", wrappedApp.Name).Return(data.AppStatus{
+		IsConfigured: true,
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ wrappedApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	wrappedApp = selfmanData.AppConfigs[wrappedApp.Name]
+
+	actions, err := makeItSo(wrappedApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	expectedWrapper := ops.WriteWrapper{
+		Script: ops.WrapperScript{
+			ArtifactPath: wrappedApp.ArtifactPath(),
+			Env: map[string]string{ "APP_MODE": "fancy" },
+			WorkingDir: wrappedApp.SourcePath(),
+			Args: []string{ "--verbose" },
+		},
+		DestinationPath: path.Join(*systemConfig.BinaryDir, wrappedApp.Name),
+		ManagedDir: systemConfig.ManagedDir(),
+	}
+	assert.Equal(t, expectedWrapper, actions[len(actions) - 1])
+}
+
+func TestMakeItSoHonorsLinkMode(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+	systemConfig.LinkMode = run.StrPtr(data.LinkModeRelative)
+
+	relativeApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "relative-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+	copiedApp := relativeApp
+	copiedApp.Name = "copied-app"
+	copiedApp.LinkMode = data.LinkModeCopy
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", relativeApp.Name).Return(data.AppStatus{ IsConfigured: true })
+	mockStorage.On("AppStatus", copiedApp.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ relativeApp, copiedApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	relativeApp = selfmanData.AppConfigs[relativeApp.Name]
+	copiedApp = selfmanData.AppConfigs[copiedApp.Name]
+
+	actions, err := makeItSo(relativeApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		ops.LinkArtifact{
+			SourcePath: relativeApp.ArtifactPath(),
+			DestinationPath: relativeApp.BinaryPath(),
+			ManagedDir: systemConfig.ManagedDir(),
+			Relative: true,
+		},
+		actions[len(actions) - 1],
+	)
+
+	actions, err = makeItSo(copiedApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		ops.CopyArtifact{
+			SourcePath: copiedApp.ArtifactPath(),
+			DestinationPath: copiedApp.BinaryPath(),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		actions[len(actions) - 1],
+	)
+}
+
+func TestMakeItSoMovesAndLinksEachBuildTarget(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	multiTargetApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "multi-target-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+		BuildTargets: []data.BuildTargetConfig{
+			{ Path: "bin/server" },
+			{ Path: "bin/cli", LinkName: "multi-cli" },
+		},
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", multiTargetApp.Name).Return(data.AppStatus{
+		IsConfigured: true,
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ multiTargetApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	multiTargetApp = selfmanData.AppConfigs[multiTargetApp.Name]
+
+	actions, err := makeItSo(multiTargetApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	artifactDir := path.Join(systemConfig.ArtifactsPath(), "multi-target-app---main")
+	expectedActions := []ops.Operation{
+		ops.MoveTarget{
+			SourcePath: path.Join(multiTargetApp.SourcePath(), "bin/server"),
+			DestinationPath: path.Join(artifactDir, "server"),
+		},
+		ops.MoveTarget{
+			SourcePath: path.Join(multiTargetApp.SourcePath(), "bin/cli"),
+			DestinationPath: path.Join(artifactDir, "multi-cli"),
+		},
+		ops.LinkArtifact{
+			SourcePath: path.Join(artifactDir, "server"),
+			DestinationPath: path.Join(*systemConfig.BinaryDir, "server"),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		ops.LinkArtifact{
+			SourcePath: path.Join(artifactDir, "multi-cli"),
+			DestinationPath: path.Join(*systemConfig.BinaryDir, "multi-cli"),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+	}
+	assert.Equal(t, expectedActions, actions[len(actions) - 4:])
+}
+
+func TestMakeItSoKeepsDirectoryArtifactWhole(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	dirApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "dir-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "v1",
+		BuildTarget: "dist",
+		Entrypoint: "bin/dir-app",
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", dirApp.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		AvailableVersions: []string{ "v1" },
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ dirApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	dirApp = selfmanData.AppConfigs[dirApp.Name]
+
+	actions, err := makeItSo(dirApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	artifactDir := path.Join(systemConfig.ArtifactsPath(), "dir-app---v1")
+	expectedActions := []ops.Operation{
+		ops.MoveTarget{
+			SourcePath: path.Join(dirApp.SourcePath(), "dist"),
+			DestinationPath: artifactDir,
+		},
+		ops.LinkArtifact{
+			SourcePath: path.Join(artifactDir, "bin/dir-app"),
+			DestinationPath: dirApp.BinaryPath(),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+	}
+	assert.Equal(t, expectedActions, actions[len(actions) - 2:])
+
+	touchFile(path.Join(dirApp.SourcePath(), "dist/bin/dir-app"))
+	touchFile(path.Join(dirApp.SourcePath(), "dist/lib/runtime.jar"))
+	for _, action := range expectedActions {
+		_, err := action.Execute(t.Context())
+		assert.NoError(t, err)
+		run.BailIfFailed(t)
+	}
+
+	status := (&data.AppManagedFiles{ AppConfigs: selfmanData.AppConfigs }).AppStatus(dirApp.Name)
+	assert.True(t, status.TargetPresent)
+	assert.True(t, status.LinkPresent)
+	assert.Equal(t, "v1", status.LinkedVersion)
+	assert.FileExists(t, path.Join(artifactDir, "lib/runtime.jar"))
+
+	// rebuilding the same version replaces the whole dir
+	touchFile(path.Join(dirApp.SourcePath(), "dist/bin/dir-app"))
+	touchFile(path.Join(dirApp.SourcePath(), "dist/lib/runtime-2.jar"))
+	for _, action := range expectedActions {
+		_, err := action.Execute(t.Context())
+		assert.NoError(t, err)
+		run.BailIfFailed(t)
+	}
+	assert.FileExists(t, path.Join(artifactDir, "lib/runtime-2.jar"))
+	assert.NoFileExists(t, path.Join(artifactDir, "lib/runtime.jar"))
+	artifactEntries, err := os.ReadDir(systemConfig.ArtifactsPath())
+	assert.NoError(t, err)
+	assert.Len(t, artifactEntries, 1, "Nothing is left over from replacing the old dir")
+	status = (&data.AppManagedFiles{ AppConfigs: selfmanData.AppConfigs }).AppStatus(dirApp.Name)
+	assert.True(t, status.LinkPresent)
+
+	removeArtifacts := ops.DeleteFilesWithPrefix{
+		TypeOfDeletion: "Delete built artifacts",
+		DirPath: systemConfig.ArtifactsPath(),
+		FilePrefix: dirApp.ArtifactFilePrefix(),
+	}
+	_, err = removeArtifacts.Execute(t.Context())
+	assert.NoError(t, err)
+	assert.NoDirExists(t, artifactDir)
+}
+
+func TestMakeItSoInstallsExtraFiles(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	appWithExtras := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "extras-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+		ExtraFileConfigs: []data.ExtraFileConfig{
+			{ Kind: data.ExtraFileMan, Path: "docs/extras-app.1" },
+			{ Kind: data.ExtraFileZshCompletion, Command: "echo \"#compdef $SELFMAN_ARTIFACT\"" },
+		},
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", appWithExtras.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		AvailableVersions: []string{ "main" },
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ appWithExtras },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	appWithExtras = selfmanData.AppConfigs[appWithExtras.Name]
+
+	actions, err := makeItSo(appWithExtras.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	storedDir := path.Join(*systemConfig.DataDir, "extra-files", "extras-app", "main")
+	manPagePath := path.Join(*systemConfig.ShareDir, "man", "man1", "extras-app.1")
+	completionPath := path.Join(*systemConfig.ShareDir, "zsh", "site-functions", "_extras-app")
+	expectedStoreActions := []ops.Operation{
+		ops.StoreExtraFile{
+			SourcePath: path.Join(appWithExtras.SourcePath(), "docs/extras-app.1"),
+			DestinationPath: path.Join(storedDir, "man", "extras-app.1"),
+		},
+		ops.CaptureExtraFile{
+			ScriptShell: "/bin/sh",
+			ScriptCmd: "echo \"#compdef $SELFMAN_ARTIFACT\"",
+			WorkingDir: appWithExtras.SourcePath(),
+			ArtifactPath: appWithExtras.ArtifactPath(),
+			DestinationPath: path.Join(storedDir, "zsh-completion", "_extras-app"),
+			LogDir: appWithExtras.LogsPath(),
+			AppName: appWithExtras.Name,
+		},
+	}
+	expectedLinkActions := []ops.Operation{
+		ops.LinkArtifact{
+			SourcePath: path.Join(storedDir, "man", "extras-app.1"),
+			DestinationPath: manPagePath,
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		ops.LinkArtifact{
+			SourcePath: path.Join(storedDir, "zsh-completion", "_extras-app"),
+			DestinationPath: completionPath,
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+	}
+	assert.Subset(t, actions, expectedStoreActions)
+	assert.Equal(t, expectedLinkActions, actions[len(actions) - 2:])
+
+	touchFile(path.Join(appWithExtras.SourcePath(), "docs/extras-app.1"))
+	for _, action := range append(expectedStoreActions, expectedLinkActions...) {
+		_, err := action.Execute(t.Context())
+		assert.NoError(t, err)
+		run.BailIfFailed(t)
+	}
+
+	completion, err := os.ReadFile(completionPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "#compdef " + appWithExtras.ArtifactPath() + "\n", string(completion))
+	assert.FileExists(t, manPagePath)
+
+	status := (&data.AppManagedFiles{ AppConfigs: selfmanData.AppConfigs }).AppStatus(
+		appWithExtras.Name,
+	)
+	assert.True(t, status.ExtraFilesStored)
+	assert.Equal(t, []string{ manPagePath, completionPath }, []string{
+		status.ExtraFiles[0].Path,
+		status.ExtraFiles[1].Path,
+	})
+	assert.True(t, status.ExtraFiles[0].Present && status.ExtraFiles[1].Present)
+
+	removeActions := deleteExtraFileOps(appWithExtras, status)
+	assert.Equal(
+		t,
+		[]ops.Operation{
+			ops.DeleteFile{ TypeOfDeletion: "Delete extra file", Path: manPagePath },
+			ops.DeleteFile{ TypeOfDeletion: "Delete extra file", Path: completionPath },
+			ops.DeleteDir{
+				TypeOfDeletion: "Delete stored extra files",
+				Path: appWithExtras.ExtraFilesPath(),
+			},
+		},
+		removeActions,
+	)
+}
+
+func TestMakeItSoCapturesExtraFilesFromFirstBuildTarget(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "toolbox",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.BuildActionMake,
+		Version: "main",
+		BuildTargets: []data.BuildTargetConfig{ { Path: "out/tool" }, { Path: "out/helper" } },
+		Env: map[string]string{ "TOOL_HOME": "/opt/tool" },
+		ExtraFileConfigs: []data.ExtraFileConfig{
+			{ Kind: data.ExtraFileMan, Path: "docs/tool.1.gz" },
+			{ Kind: data.ExtraFileBashCompletion, Command: "$SELFMAN_ARTIFACT completions" },
+		},
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(systemConfig, []data.AppConfig{ app }, &mockStorage)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	actions, err := makeItSo(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	storedDir := path.Join(*systemConfig.DataDir, "extra-files", "toolbox", "main")
+	assert.Contains(t, actions, ops.CaptureExtraFile{
+		ScriptShell: "/bin/sh",
+		ScriptCmd: "$SELFMAN_ARTIFACT completions",
+		WorkingDir: app.SourcePath(),
+		ArtifactPath: path.Join(app.ArtifactPath(), "tool"),
+		DestinationPath: path.Join(storedDir, "bash-completion", "toolbox"),
+		Env: run.Env{ Vars: map[string]string{ "TOOL_HOME": "/opt/tool" } },
+		LogDir: app.LogsPath(),
+		AppName: app.Name,
+	})
+	assert.Contains(t, actions, ops.LinkArtifact{
+		SourcePath: path.Join(storedDir, "man", "tool.1.gz"),
+		DestinationPath: path.Join(*systemConfig.ShareDir, "man", "man1", "tool.1.gz"),
+		ManagedDir: systemConfig.ManagedDir(),
+	})
+}
+
+func TestMakeItSoWritesDesktopEntryWithIcon(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	guiApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "gui-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+		DesktopEntry: &data.DesktopEntryConfig{
+			Name: "GUI App",
+			Icon: "assets/logo.svg",
+			Categories: []string{ "Graphics" },
+		},
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", guiApp.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ guiApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	guiApp = selfmanData.AppConfigs[guiApp.Name]
+
+	actions, err := makeItSo(guiApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	storedIconPath := path.Join(
+		*systemConfig.DataDir, "extra-files", "gui-app", "main", "desktop-icon", "gui-app.svg",
+	)
+	iconPath := path.Join(*systemConfig.ShareDir, "icons", "gui-app.svg")
+	assert.Contains(t, actions, ops.StoreExtraFile{
+		SourcePath: path.Join(guiApp.SourcePath(), "assets/logo.svg"),
+		DestinationPath: storedIconPath,
+	})
+	expectedActions := []ops.Operation{
+		ops.LinkArtifact{
+			SourcePath: storedIconPath,
+			DestinationPath: iconPath,
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		ops.WriteDesktopEntry{
+			Entry: ops.DesktopEntry{
+				Name: "GUI App",
+				ExecPath: guiApp.BinaryPath(),
+				IconPath: iconPath,
+				Categories: []string{ "Graphics" },
+			},
+			DestinationPath: path.Join(*systemConfig.ShareDir, "applications", "gui-app.desktop"),
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+	}
+	assert.Equal(t, expectedActions, actions[len(actions) - 2:])
+}
+
+func TestMakeItSoUsesToolchainBuildOutputs(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	cargoApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "Rusty",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.BuildActionCargo,
+		Version: "main",
+	}
+	goApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "gopher",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.BuildActionGo,
+		Version: "main",
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", cargoApp.Name).Return(data.AppStatus{ IsConfigured: true })
+	mockStorage.On("AppStatus", goApp.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ cargoApp, goApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	cargoApp = selfmanData.AppConfigs[cargoApp.Name]
+	goApp = selfmanData.AppConfigs[goApp.Name]
+
+	actions, err := makeItSo(cargoApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	assert.Contains(t, actions, ops.BuildWithCargo{
+		SourcePath: cargoApp.SourcePath(),
+		LogDir: cargoApp.LogsPath(),
+		AppName: cargoApp.Name,
+	})
+	assert.Contains(t, actions, ops.MoveTarget{
+		SourcePath: path.Join(cargoApp.SourcePath(), "target/release/rusty"),
+		DestinationPath: cargoApp.ArtifactPath(),
+	})
+
+	actions, err = makeItSo(goApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	assert.Contains(t, actions, ops.BuildWithGo{
+		SourcePath: goApp.SourcePath(),
+		OutputPath: path.Join(goApp.SourcePath(), "gopher"),
+		LogDir: goApp.LogsPath(),
+		AppName: goApp.Name,
+	})
+}
+
+func TestMakeItSoBuildsWithAppEnvironment(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "envy",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.BuildActionMake,
+		Version: "v1.2",
+		Env: map[string]string{ "RELEASE": "%VERSION%" },
+		PathPrepend: []string{ "/opt/toolchain-%VERSION%/bin" },
+		CleanEnv: true,
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(systemConfig, []data.AppConfig{ app }, &mockStorage)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	actions, err := makeItSo(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	assert.Contains(t, actions, ops.BuildWithMake{
+		SourcePath: app.SourcePath(),
+		Env: run.Env{
+			Vars: map[string]string{ "RELEASE": "v1.2" },
+			PathPrepend: []string{ "/opt/toolchain-v1.2/bin" },
+			Clean: true,
+		},
+		LogDir: app.LogsPath(),
+		AppName: app.Name,
+	})
+}
+
+func TestMakeItSoVerifiesArtifactBeforeLinking(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "checked",
+		Flavor: data.FlavorWebFetch,
+		WebUrl: run.StrPtr("doesn't matter"),
+		BuildAction: data.BuildActionScript,
+		BuildCmd: run.StrPtr("doesn't matter"),
+		Version: "1.2",
+		VerifyCmd: "%ARTIFACT% --version --channel %CHANNEL%",
+		VerifyOutput: `^checked %VERSION%-%CHANNEL%`,
+		MiscVars: map[string]string{ "CHANNEL": "beta+1" },
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(systemConfig, []data.AppConfig{ app }, &mockStorage)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	actions, err := makeItSo(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	stagingPath := path.Join(systemConfig.StagingPath(), "checked---1.2")
+	verifyOp := ops.VerifyArtifact{
+		ArtifactPath: stagingPath,
+		DiscardPath: stagingPath,
+		ScriptShell: *systemConfig.ScriptShell,
+		Cmd: "%ARTIFACT% --version --channel beta+1",
+		ExpectedOutput: `^checked 1\.2-beta\+1`,
+		LogDir: app.LogsPath(),
+		AppName: app.Name,
+	}
+	verifyIndex := slices.Index(actions, ops.Operation(verifyOp))
+	assert.Greater(t, verifyIndex, 0)
+	run.BailIfFailed(t)
+	assert.Equal(
+		t,
+		ops.MoveTarget{ SourcePath: app.BuildTargetPath(), DestinationPath: stagingPath },
+		actions[verifyIndex - 1],
+		"The build is verified before it is moved into the artifacts dir",
+	)
+	assert.Equal(
+		t,
+		ops.MoveTarget{ SourcePath: stagingPath, DestinationPath: app.ArtifactPath() },
+		actions[verifyIndex + 1],
+	)
+	assert.IsType(t, ops.LinkArtifact{}, actions[verifyIndex + 2])
+}
+
+func TestMakeItSoKeepsWorkingArtifactWhenRebuildFailsVerify(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "checked",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "v1",
+		BuildTarget: "checked.sh",
+		VerifyCmd: "%ARTIFACT%",
+		VerifyOutput: "^ok$",
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(systemConfig, []data.AppConfig{ app }, &mockStorage)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	writeBuild := func(output string) {
+		touchFile(app.BuildTargetPath())
+		script := []byte("#!/bin/sh\necho " + output + "\n")
+		run.AssertNoErr(os.WriteFile(app.BuildTargetPath(), script, 0o755))
+	}
+	installOps := append(app.GetMoveTargetOps(), app.GetLinkArtifactOps(ops.ClobberPolicy{})...)
+
+	writeBuild("ok")
+	for _, action := range installOps {
+		_, err := action.Execute(t.Context())
+		assert.NoError(t, err)
+		run.BailIfFailed(t)
+	}
+
+	// rebuilding the same version with a broken build
+	writeBuild("broken")
+	var verifyErr error
+	for _, action := range installOps {
+		_, verifyErr = action.Execute(t.Context())
+		if verifyErr != nil { break }
+	}
+	assert.ErrorContains(t, verifyErr, "Verification output did not match")
+	assert.ErrorContains(t, verifyErr, "Removed failed artifact: " + app.StagingPath())
+	assert.NoFileExists(t, app.StagingPath())
+
+	output, err := run.NewCmd(app.BinaryPath()).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", output, "The link still runs the previous build")
+	artifactEntries, err := os.ReadDir(systemConfig.ArtifactsPath())
+	assert.NoError(t, err)
+	assert.Len(t, artifactEntries, 1)
+}
+
+func TestMakeItSoRunsHooksAroundBuildAndLink(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "hooked",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.BuildActionMake,
+		Version: "v3",
+		Hooks: data.HooksConfig{
+			PreBuild: "./gen-sources",
+			PostBuild: "%ARTIFACT_CACHE% refresh %VERSION%",
+			PostLink: "systemctl --user restart hooked",
+			PreRemove: "doesn't matter",
+		},
+		MiscVars: map[string]string{ "ARTIFACT_CACHE": "/opt/cache" },
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(data.AppStatus{ IsConfigured: true })
+
+	selfmanData, err := data.SelfmanFromValues(systemConfig, []data.AppConfig{ app }, &mockStorage)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	actions, err := makeItSo(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	hookOp := func(hook string, cmd string) ops.RunHook {
+		return ops.RunHook{
+			Hook: hook,
+			ScriptShell: *systemConfig.ScriptShell,
+			Cmd: cmd,
+			AppVars: map[string]string{
+				"SELFMAN_APP_NAME": "hooked",
+				"SELFMAN_APP_VERSION": "v3",
+				"SELFMAN_SOURCE_PATH": app.SourcePath(),
+				"SELFMAN_ARTIFACT_PATH": app.ArtifactPath(),
+			},
+			CommitRepoPath: app.SourcePath(),
+			WorkingDir: app.SourcePath(),
+			FallbackDir: *systemConfig.DataDir,
+			LogDir: app.LogsPath(),
+			AppName: app.Name,
+		}
+	}
+	buildIndex := slices.IndexFunc(actions, func(action ops.Operation) bool {
+		_, isBuild := action.(ops.BuildWithMake)
+		return isBuild
+	})
+	assert.Greater(t, buildIndex, 0)
+	run.BailIfFailed(t)
+	assert.Equal(t, hookOp(data.HookPreBuild, "./gen-sources"), actions[buildIndex - 1])
+	assert.IsType(t, ops.MoveTarget{}, actions[buildIndex + 1])
+	assert.Equal(
+		t,
+		hookOp(data.HookPostBuild, "/opt/cache refresh v3"),
+		actions[buildIndex + 2],
+	)
+	assert.Equal(
+		t,
+		hookOp(data.HookPostLink, "systemctl --user restart hooked"),
+		actions[len(actions) - 1],
+	)
+	for _, action := range actions {
+		if hook, isHook := action.(ops.RunHook); isHook {
+			assert.NotEqual(t, data.HookPreRemove, hook.Hook)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/spf13/cobra"
+)
+
+const releaseCmdOptionKeepFiles = "keep-files"
+
+func CreateReleaseCmd() SelfmanCommand {
+	selfmanCmd := SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "release [flags] app-name",
+			Short: "Stop providing an application, handing it back to whatever else is on PATH",
+		},
+		runFunc: runReleaseCmd,
+	}
+
+	selfmanCmd.cobraCmd.Flags().Bool(
+		releaseCmdOptionKeepFiles,
+		false,
+		"Keep the application's source & artifacts, so it can be quickly restored later",
+	)
+
+	return selfmanCmd
+}
+
+func runReleaseCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
+	selfmanData, err := produceSelfmanData(cmd)
+	if err != nil { return nil, err }
+
+	if len(args) < 1 {
+		return nil,
+			fmt.Errorf("Release command expects an application name, but one was not provided")
+	}
+	keepFiles, err := cmd.Flags().GetBool(releaseCmdOptionKeepFiles)
+	run.AssertNoErr(err)
+
+	result, ops, err := releaseApp(args[0], keepFiles, os.Getenv("PATH"), selfmanData)
+	if err != nil { return nil, err }
+
+	app, appStatus := selfmanData.AppStatus(args[0])
+	return &SelfmanResult{
+		textOutput: result,
+		followUpOutput: serviceCommandsAfterRemove(app, appStatus),
+		operations: ops,
+		systemConfig: selfmanData.SystemConfig,
+	}, nil
+}
+
+type releaseResult struct {
+	appName string
+	commands []releasedCommand
+	keptFiles bool
+}
+
+type releasedCommand struct {
+	name string
+	// The binary which will be run in place of selfman's link, if any
+	replacement string
+}
+
+func (self releaseResult) String() string {
+	var buf strings.Builder
+	for _, command := range self.commands {
+		if len(command.replacement) > 0 {
+			buf.WriteString(fmt.Sprintf(
+				"After release, \"%s\" will run: %s\n",
+				command.name, command.replacement,
+			))
+		} else {
+			buf.WriteString(fmt.Sprintf(
+				"⚠ No other \"%s\" was found on PATH, the command will no longer be available\n",
+				command.name,
+			))
+		}
+	}
+	if self.keptFiles {
+		buf.WriteString(fmt.Sprintf(
+			"Source & artifacts are kept, run \"selfman repair %s\" to restore the link\n",
+			self.appName,
+		))
+	}
+	return buf.String()
+}
+
+// Removes selfman's links for an app, leaving its config in place. Unless files are kept, the
+// app's source & artifacts are removed as well.
+func releaseApp(
+	name string,
+	keepFiles bool,
+	pathEnv string,
+	selfmanData data.Selfman,
+) (releaseResult, []ops.Operation, error) {
+	app, appStatus := selfmanData.AppStatus(name)
+	if !appStatus.IsConfigured {
+		return releaseResult{}, nil,
+			fmt.Errorf("Could not find a configured application with name \"%s\"", name)
+	}
+	if !appStatus.LinkPresent {
+		return releaseResult{}, nil,
+			fmt.Errorf("Application \"%s\" is not linked by selfman, nothing to release", name)
+	}
+
+	actions := make([]ops.Operation, 0, 4)
+	for _, artifact := range app.Artifacts() {
+		actions = append(actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete binary symlink",
+			Path: artifact.BinaryPath,
+		})
+	}
+	if appStatus.LibLinkPresent {
+		actions = append(actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete library link",
+			Path: app.LibPath(),
+		})
+	}
+	// the desktop entry and service unit run the released link, so would no longer work
+	if appStatus.DesktopEntryPresent {
+		actions = append(actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete desktop entry",
+			Path: app.DesktopEntryPath(),
+		})
+	}
+	actions = append(actions, deleteServiceUnitOps(app, appStatus)...)
+
+	// selfman can't get back an artifact it didn't build, so those are always kept
+	if !keepFiles && app.CanObtainSource() {
+		// versioned links would be left dangling once their artifacts are gone
+		actions = append(actions, deleteVersionLinkOps(app, appStatus)...)
+		actions = append(actions, deleteExtraFileOps(app, appStatus)...)
+		actions = append(
+			actions,
+			ops.DeleteFilesWithPrefix{
+				TypeOfDeletion: "Delete built artifacts",
+				DirPath: app.SystemConfig.ArtifactsPath(),
+				FilePrefix: app.ArtifactFilePrefix(),
+			},
+			ops.DeleteDir{
+				TypeOfDeletion: "Delete source directory",
+				Path: app.SourcePath(),
+			},
+		)
+	}
+
+	result := releaseResult{
+		appName: name,
+		keptFiles: keepFiles || !app.CanObtainSource(),
+	}
+	for _, artifact := range app.Artifacts() {
+		command := releasedCommand{ name: artifact.LinkName }
+		for _, binPath := range run.FindExecutablesOnPath(artifact.LinkName, pathEnv) {
+			if filepath.Clean(binPath) == filepath.Clean(artifact.BinaryPath) { continue }
+			command.replacement = binPath
+			break
+		}
+		result.commands = append(result.commands, command)
+	}
+
+	return result, actions, nil
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/data/mocks"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseRemovesLinkAndFindsReplacement(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+	systemDir := t.TempDir()
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "released-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkPresent: true,
+	})
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ app },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	touchFile(app.BinaryPath())
+	systemBinary := path.Join(systemDir, app.Name)
+	touchFile(systemBinary)
+	pathEnv := *systemConfig.BinaryDir + ":" + systemDir
+
+	result, actions, err := releaseApp(app.Name, false, pathEnv, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	expectedActions := []ops.Operation{
+		ops.DeleteFile{
+			TypeOfDeletion: "Delete binary symlink",
+			Path: app.BinaryPath(),
+		},
+		ops.DeleteFilesWithPrefix{
+			TypeOfDeletion: "Delete built artifacts",
+			DirPath: systemConfig.ArtifactsPath(),
+			FilePrefix: app.ArtifactFilePrefix(),
+		},
+		ops.DeleteDir{
+			TypeOfDeletion: "Delete source directory",
+			Path: app.SourcePath(),
+		},
+	}
+	assert.Equal(t, expectedActions, actions)
+	assert.Equal(
+		t,
+		[]releasedCommand{ { name: app.Name, replacement: systemBinary } },
+		result.commands,
+	)
+	assert.False(t, result.keptFiles)
+
+	result, actions, err = releaseApp(app.Name, true, *systemConfig.BinaryDir, selfmanData)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedActions[:1], actions, "Kept files must not be deleted")
+	assert.Equal(
+		t,
+		[]releasedCommand{ { name: app.Name } },
+		result.commands,
+		"There is no replacement if nothing else is on PATH",
+	)
+	assert.True(t, result.keptFiles)
+}
+
+func TestReleaseRequiresALink(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	app := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "unlinked-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkPresent: false,
+	})
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ app },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	_, _, err = releaseApp(app.Name, false, "", selfmanData)
+	assert.Error(t, err, "Releasing an app which selfman is not providing must fail")
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/lorentzforces/selfman/internal/data"
 	"github.com/lorentzforces/selfman/internal/ops"
@@ -32,7 +33,7 @@ func CreateRemoveCmd() SelfmanCommand {
 
 func runRemoveCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 	if err := validatePrereqs(); err != nil { return nil, err }
-	selfmanData, err := data.Produce()
+	selfmanData, err := produceSelfmanData(cmd)
 	if err != nil { return nil, err }
 
 	if len(args) < 1 {
@@ -44,9 +45,12 @@ func runRemoveCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
 	ops, err := removeApp(args[0], removeSource, selfmanData)
 	if err != nil { return nil, err }
 
+	app, appStatus := selfmanData.AppStatus(args[0])
 	return &SelfmanResult{
 		textOutput: nil,
+		followUpOutput: serviceCommandsAfterRemove(app, appStatus),
 		operations: ops,
+		systemConfig: selfmanData.SystemConfig,
 	}, nil
 }
 
@@ -59,24 +63,44 @@ func removeApp(name string, removeSource bool, selfmanData data.Selfman) ([]ops.
 		return nil, fmt.Errorf("Application \"%s\" has not been installed, no source present", name)
 	}
 
-	// by default, do not delete the source path
-	actions := []ops.Operation{
-		ops.DeleteFile{
+	actions := app.GetHookOps(data.HookPreRemove)
+
+	// files at link paths which selfman did not create are left alone
+	foreignLinkPaths := appStatus.ForeignLinkPaths()
+	for _, artifact := range app.Artifacts() {
+		if slices.Contains(foreignLinkPaths, artifact.BinaryPath) { continue }
+		actions = append(actions, ops.DeleteFile{
 			TypeOfDeletion: "Delete binary symlink",
-			Path: app.BinaryPath(),
-		},
-		ops.DeleteFile{
+			Path: artifact.BinaryPath,
+		})
+	}
+	if !appStatus.LibLinkIsForeign {
+		actions = append(actions, ops.DeleteFile{
 			TypeOfDeletion: "Delete library link",
 			Path: app.LibPath(),
-		},
-		ops.DeleteFilesWithPrefix{
-			TypeOfDeletion: "Delete built artifacts",
-			DirPath: app.SystemConfig.ArtifactsPath(),
-			FilePrefix: app.Name + "---",
-		},
+		})
 	}
 
-	if removeSource {
+	actions = append(actions, deleteVersionLinkOps(app, appStatus)...)
+	actions = append(actions, deleteExtraFileOps(app, appStatus)...)
+	if appStatus.DesktopEntryPresent {
+		actions = append(actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete desktop entry",
+			Path: app.DesktopEntryPath(),
+		})
+	}
+
+	actions = append(actions, deleteServiceUnitOps(app, appStatus)...)
+
+	// by default, do not delete the source path
+	actions = append(actions, ops.DeleteFilesWithPrefix{
+		TypeOfDeletion: "Delete built artifacts",
+		DirPath: app.SystemConfig.ArtifactsPath(),
+		FilePrefix: app.ArtifactFilePrefix(),
+	})
+
+	// apps which selfman can't obtain the source for have no source dir
+	if removeSource && app.CanObtainSource() {
 		actions = append(actions, ops.DeleteDir{
 			TypeOfDeletion: "Delete source directory",
 			Path: app.SourcePath(),
@@ -85,3 +109,51 @@ func removeApp(name string, removeSource bool, selfmanData data.Selfman) ([]ops.
 
 	return actions, nil
 }
+
+// Only extra files selfman placed are deleted, along with selfman's stored copies of them.
+func deleteExtraFileOps(app data.AppConfig, appStatus data.AppStatus) []ops.Operation {
+	actions := make([]ops.Operation, 0, len(appStatus.ExtraFiles) + 1)
+	for _, extraFile := range appStatus.ExtraFiles {
+		if !extraFile.Present { continue }
+		actions = append(actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete extra file",
+			Path: extraFile.Path,
+		})
+	}
+	if appStatus.ExtraFilesDirPresent {
+		actions = append(actions, ops.DeleteDir{
+			TypeOfDeletion: "Delete stored extra files",
+			Path: app.ExtraFilesPath(),
+		})
+	}
+	return actions
+}
+
+func deleteServiceUnitOps(app data.AppConfig, appStatus data.AppStatus) []ops.Operation {
+	if !appStatus.ServiceUnitPresent { return nil }
+
+	actions := []ops.Operation{
+		ops.DeleteFile{
+			TypeOfDeletion: "Delete service unit",
+			Path: app.ServiceUnitPath(),
+		},
+	}
+	if app.Service != nil {
+		actions = append(actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete service enablement link",
+			Path: app.ServiceEnabledLinkPath(),
+		})
+	}
+	return actions
+}
+
+func deleteVersionLinkOps(app data.AppConfig, appStatus data.AppStatus) []ops.Operation {
+	actions := make([]ops.Operation, 0, len(appStatus.VersionLinks))
+	for _, version := range appStatus.VersionLinks {
+		actions = append(actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete versioned binary symlink",
+			Path: app.VersionedBinaryPath(version),
+		})
+	}
+	return actions
+}
@@ -145,3 +145,143 @@ func TestRemoveCommandRemovesSourceWhenAsked(t *testing.T) {
 	}
 	assert.Equal(t, expectedActions, actions)
 }
+
+func TestRemoveCommandLeavesForeignFilesAtLinkPaths(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	appToRemove := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "shadowed-app",
+		Flavor: "git",
+		RemoteRepo: run.StrPtr("git@github.com:github/gitignore.git"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", appToRemove.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkIsForeign: true,
+		LibLinkIsForeign: true,
+		Links: []data.LinkStatus{
+			{
+				LinkName: appToRemove.Name,
+				Path: appToRemove.BinaryPath(),
+				Foreign: true,
+			},
+		},
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{appToRemove},
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	actions, err := removeApp(appToRemove.Name, false, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	expectedActions := []ops.Operation{
+		ops.DeleteFilesWithPrefix{
+			TypeOfDeletion: "Delete built artifacts",
+			DirPath: systemConfig.ArtifactsPath(),
+			FilePrefix: appToRemove.Name + "---",
+		},
+	}
+	assert.Equal(t, expectedActions, actions)
+}
+
+func TestRemoveCommandRemovesVersionedLinks(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	appToRemove := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "versioned-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "v2",
+		LinkVersions: true,
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", appToRemove.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkPresent: true,
+		VersionLinks: []string{ "v1", "v2" },
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ appToRemove },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	actions, err := removeApp(appToRemove.Name, false, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	for _, version := range []string{ "v1", "v2" } {
+		assert.Contains(t, actions, ops.DeleteFile{
+			TypeOfDeletion: "Delete versioned binary symlink",
+			Path: appToRemove.VersionedBinaryPath(version),
+		})
+	}
+}
+
+func TestRemoveRunsPreRemoveHookFirst(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	appToRemove := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "hooked",
+		Flavor: data.FlavorWebFetch,
+		WebUrl: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "1.0",
+		Hooks: data.HooksConfig{ PreRemove: "notify-team removing $SELFMAN_APP_NAME" },
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", appToRemove.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkPresent: true,
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ appToRemove },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	actions, err := removeApp(appToRemove.Name, false, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Equal(
+		t,
+		ops.RunHook{
+			Hook: data.HookPreRemove,
+			ScriptShell: *systemConfig.ScriptShell,
+			Cmd: "notify-team removing $SELFMAN_APP_NAME",
+			AppVars: appToRemove.HookVars(),
+			WorkingDir: appToRemove.SourcePath(),
+			FallbackDir: *systemConfig.DataDir,
+			LogDir: appToRemove.LogsPath(),
+			AppName: appToRemove.Name,
+		},
+		actions[0],
+	)
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/spf13/cobra"
+)
+
+func CreateRepairCmd() SelfmanCommand {
+	selfmanCmd := SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "repair [flags] app-name",
+			Short: "Perform only the operations needed to fix a partially-present application",
+		},
+		runFunc: runRepairCmd,
+	}
+
+	addClobberFlags(selfmanCmd.cobraCmd)
+
+	return selfmanCmd
+}
+
+func runRepairCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
+	if err := validatePrereqs(); err != nil { return nil, err }
+	selfmanData, err := produceSelfmanData(cmd)
+	if err != nil { return nil, err }
+
+	if len(args) < 1 {
+		return nil,
+			fmt.Errorf("Repair command expects an application name, but one was not provided")
+	}
+
+	clobber := clobberPolicyFromFlags(cmd, selfmanData.SystemConfig)
+	ops, err := repairApp(args[0], clobber, selfmanData)
+	if err != nil { return nil, err }
+
+	result := &SelfmanResult{
+		textOutput: nil,
+		operations: ops,
+		systemConfig: selfmanData.SystemConfig,
+	}
+	if len(ops) == 0 {
+		result.textOutput = repairNothingToDo{ appName: args[0] }
+	} else {
+		app, appStatus := selfmanData.AppStatus(args[0])
+		result.followUpOutput = serviceCommandsAfterInstall(app, appStatus)
+	}
+	return result, nil
+}
+
+type repairNothingToDo struct {
+	appName string
+}
+
+func (self repairNothingToDo) String() string {
+	return fmt.Sprintf("Application \"%s\" is fully present, nothing to repair", self.appName)
+}
+
+// Plans the minimal operations needed to bring a partially-present app back to a fully-present
+// state. Unlike make-it-so, this never fetches updates, and never rebuilds an artifact which is
+// already present.
+func repairApp(
+	name string,
+	clobber ops.ClobberPolicy,
+	selfmanData data.Selfman,
+) ([]ops.Operation, error) {
+	app, appStatus := selfmanData.AppStatus(name)
+	if !appStatus.IsConfigured {
+		return nil, fmt.Errorf("Could not find a configured application with name \"%s\"", name)
+	}
+	if !appStatus.SourcePresent && !appStatus.TargetPresent && !appStatus.LinkPresent {
+		return nil, fmt.Errorf(
+			"Application \"%s\" is not installed, use make-it-so to install it",
+			name,
+		)
+	}
+
+	if !app.CanObtainSource() && !appStatus.TargetPresent {
+		return nil, unobtainableArtifactError(app)
+	}
+
+	actions := make([]ops.Operation, 0, 6)
+
+	if !appStatus.SourcePresent {
+		actions = append(actions, app.GetObtainSourceOp())
+	}
+
+	// a present source may have any version checked out, but if we're about to build from it (or
+	// have just obtained it) it needs to be the configured version
+	if !appStatus.SourcePresent || !appStatus.TargetPresent {
+		if versionOp := app.GetSelectVersionOp(); versionOp != nil {
+			actions = append(actions, versionOp)
+		}
+	}
+
+	if !appStatus.TargetPresent {
+		actions = append(actions, app.GetHookOps(data.HookPreBuild)...)
+		actions = append(actions, app.GetBuildOp())
+		if !app.KeepBinWithSource {
+			actions = append(actions, app.GetMoveTargetOps()...)
+		}
+		actions = append(actions, app.GetHookOps(data.HookPostBuild)...)
+	}
+
+	if !appStatus.TargetPresent || !appStatus.ExtraFilesStored {
+		actions = append(actions, app.GetStoreExtraFileOps()...)
+	}
+
+	if !appStatus.LinkPresent {
+		actions = append(actions, app.GetLinkArtifactOps(clobber)...)
+	}
+
+	if app.LinkVersions && !slices.Contains(appStatus.VersionLinks, app.Version) {
+		actions = append(actions, app.GetLinkVersionedArtifactOp(clobber))
+	}
+
+	if app.LinkSourceAsLib && !appStatus.LibLinkPresent {
+		actions = append(actions, app.GetLinkLibraryOp(clobber))
+	}
+
+	for _, extraFile := range app.ExtraFiles() {
+		isPresent := slices.ContainsFunc(appStatus.ExtraFiles, func(status data.LinkStatus) bool {
+			return status.Path == extraFile.InstallPath && status.Present
+		})
+		if isPresent { continue }
+		actions = append(actions, app.GetLinkExtraFileOp(extraFile, clobber))
+	}
+
+	if app.DesktopEntry != nil && !appStatus.DesktopEntryPresent {
+		actions = append(actions, app.GetWriteDesktopEntryOp(clobber))
+	}
+
+	if app.Service != nil && !appStatus.ServiceUnitPresent {
+		actions = append(actions, app.GetWriteServiceUnitOp(clobber))
+	}
+
+	if !appStatus.LinkPresent {
+		actions = append(actions, app.GetHookOps(data.HookPostLink)...)
+	}
+
+	return actions, nil
+}
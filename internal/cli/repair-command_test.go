@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/data/mocks"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func repairTestData(t *testing.T, app data.AppConfig, status data.AppStatus) data.Selfman {
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", app.Name).Return(status)
+
+	selfmanData, err := data.SelfmanFromValues(
+		app.SystemConfig,
+		[]data.AppConfig{ app },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	return selfmanData
+}
+
+func repairTestGitApp(systemConfig *data.SystemConfig) data.AppConfig {
+	return data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "broken-app",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("git@github.com:github/gitignore.git"),
+		BuildAction: data.BuildActionScript,
+		BuildCmd: run.StrPtr("make build"),
+		Version: "main",
+		LinkSourceAsLib: true,
+	}
+}
+
+func TestRepairRefusesAppsWhichAreNotInstalled(t *testing.T) {
+	app := repairTestGitApp(data.DefaultTestConfig())
+	selfmanData := repairTestData(t, app, data.AppStatus{ IsConfigured: true })
+
+	_, err := repairApp(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.Error(t, err, "Repair must not perform a full install")
+
+	_, err = repairApp("not-configured", ops.ClobberPolicy{}, selfmanData)
+	assert.Error(t, err, "Repair must reject apps which are not configured")
+}
+
+func TestRepairOnlyRelinksWhenArtifactIsPresent(t *testing.T) {
+	app := repairTestGitApp(data.DefaultTestConfig())
+	selfmanData := repairTestData(t, app, data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkPresent: false,
+		LibLinkPresent: true,
+	})
+
+	actions, err := repairApp(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	expectedActions := []ops.Operation{
+		ops.LinkArtifact{
+			SourcePath: app.ArtifactPath(),
+			DestinationPath: app.BinaryPath(),
+			ManagedDir: app.SystemConfig.ManagedDir(),
+		},
+	}
+	assert.Equal(t, expectedActions, actions)
+}
+
+func TestRepairRebuildsFromPresentSourceWithoutCloning(t *testing.T) {
+	app := repairTestGitApp(data.DefaultTestConfig())
+	selfmanData := repairTestData(t, app, data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: false,
+		LinkPresent: false,
+		LibLinkPresent: false,
+	})
+
+	actions, err := repairApp(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	expectedActions := []ops.Operation{
+		ops.GitCheckoutRef{
+			RepoPath: app.SourcePath(),
+			RefName: app.Version,
+			LogDir: app.LogsPath(),
+			AppName: app.Name,
+		},
+		ops.BuildWithScript{
+			SourcePath: app.SourcePath(),
+			ScriptShell: "/bin/sh",
+			ScriptCmd: "make build",
+			LogDir: app.LogsPath(),
+			AppName: app.Name,
+		},
+		ops.MoveTarget{
+			SourcePath: app.BuildTargetPath(),
+			DestinationPath: app.ArtifactPath(),
+		},
+		ops.LinkArtifact{
+			SourcePath: app.ArtifactPath(),
+			DestinationPath: app.BinaryPath(),
+			ManagedDir: app.SystemConfig.ManagedDir(),
+		},
+		ops.LinkLibrary{
+			SourcePath: app.SourcePath(),
+			DestinationPath: app.LibPath(),
+			ManagedDir: app.SystemConfig.ManagedDir(),
+		},
+	}
+	assert.Equal(t, expectedActions, actions)
+}
+
+func TestRepairObtainsMissingSourceWithoutRebuilding(t *testing.T) {
+	app := repairTestGitApp(data.DefaultTestConfig())
+	selfmanData := repairTestData(t, app, data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: false,
+		TargetPresent: true,
+		LinkPresent: true,
+		LibLinkPresent: true,
+	})
+
+	actions, err := repairApp(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs[app.Name]
+
+	expectedActions := []ops.Operation{
+		ops.GitClone{
+			RepoUrl: *app.RemoteRepo,
+			DestinationPath: app.SourcePath(),
+			Network: app.NetworkPolicy(),
+			LogDir: app.LogsPath(),
+			AppName: app.Name,
+		},
+		ops.GitCheckoutRef{
+			RepoPath: app.SourcePath(),
+			RefName: app.Version,
+			LogDir: app.LogsPath(),
+			AppName: app.Name,
+		},
+	}
+	assert.Equal(t, expectedActions, actions)
+}
+
+func TestRepairDoesNothingForFullyPresentApp(t *testing.T) {
+	app := repairTestGitApp(data.DefaultTestConfig())
+	selfmanData := repairTestData(t, app, data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkPresent: true,
+		LibLinkPresent: true,
+	})
+
+	actions, err := repairApp(app.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	assert.Empty(t, actions)
+}
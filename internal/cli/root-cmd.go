@@ -7,6 +7,12 @@ import (
 const (
 	globalOptionDryRun = "dry-run"
 	globalOptionVerbose = "verbose"
+	globalOptionConfig = "config"
+	globalOptionDataDir = "data-dir"
+	globalOptionBinDir = "bin-dir"
+	globalOptionLibDir = "lib-dir"
+	globalOptionAppConfigDir = "app-config-dir"
+	globalOptionProfile = "profile"
 )
 
 func CreateRootCmd() *cobra.Command {
@@ -28,7 +34,38 @@ func CreateRootCmd() *cobra.Command {
 		globalOptionVerbose,
 		"v",
 		false,
-		"Enable display of additional information when executing commands",
+		"Enable display of additional information when executing commands, including live " +
+			"output from builds and git",
+	)
+	rootCmd.PersistentFlags().String(
+		globalOptionConfig,
+		"",
+		"Path to the selfman config file (overrides $SELFMAN_CONFIG)",
+	)
+	rootCmd.PersistentFlags().String(
+		globalOptionProfile,
+		"",
+		"Name of the configured profile to use (overrides $SELFMAN_PROFILE)",
+	)
+	rootCmd.PersistentFlags().String(
+		globalOptionDataDir,
+		"",
+		"Directory to use for selfman data (overrides $SELFMAN_DATA_DIR)",
+	)
+	rootCmd.PersistentFlags().String(
+		globalOptionBinDir,
+		"",
+		"Directory in which to place binary links (overrides $SELFMAN_BIN_DIR)",
+	)
+	rootCmd.PersistentFlags().String(
+		globalOptionLibDir,
+		"",
+		"Directory in which to place library links (overrides $SELFMAN_LIB_DIR)",
+	)
+	rootCmd.PersistentFlags().String(
+		globalOptionAppConfigDir,
+		"",
+		"Directory in which to search for app config files (overrides $SELFMAN_APP_CONFIG_DIR)",
 	)
 
 	addSelfmanCommands(
@@ -37,11 +74,17 @@ func CreateRootCmd() *cobra.Command {
 			CreateListCmd(),
 			CreateMakeItSoCmd(),
 			CreateCheckCmd(),
+			CreateLogsCmd(),
 			CreateRemoveCmd(),
+			CreateRepairCmd(),
+			CreateReleaseCmd(),
+			CreateUseCmd(),
+			CreateAdoptCmd(),
+			CreateDoctorCmd(),
 			CreateVersionCmd(),
+			CreateConfigCmd(),
 		},
 	)
-	// TODO: intake binary for static-binary app
 	// TODO(?): rollback?
 	// TODO(?): list previous versions?
 	// TODO: some kind of validation command for configuration? (roll into check?)
@@ -51,7 +94,11 @@ func CreateRootCmd() *cobra.Command {
 
 func addSelfmanCommands(rootCmd *cobra.Command, cmds []SelfmanCommand) {
 	for _, cmd := range cmds {
-		cmd.cobraCmd.RunE = cmd.RunSelfmanCommand
+		// commands which only group subcommands have nothing to run themselves
+		if cmd.runFunc != nil {
+			cmd.cobraCmd.RunE = cmd.RunSelfmanCommand
+		}
+		addSelfmanCommands(cmd.cobraCmd, cmd.subCommands)
 		rootCmd.AddCommand(cmd.cobraCmd)
 	}
 }
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// The systemctl commands needed for changes to an app's service unit to take effect, for apps
+// which ask for them to be printed.
+type serviceCommands struct {
+	appName string
+	commands []string
+}
+
+func (self serviceCommands) String() string {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf(
+		"For the changes to the \"%s\" service to take effect, run:\n",
+		self.appName,
+	))
+	for _, command := range self.commands {
+		buf.WriteString(run.IndentChars + command + "\n")
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Once an app is installed or updated, a new service needs to be enabled and an existing one
+// restarted to pick up the new binary.
+func serviceCommandsAfterInstall(app data.AppConfig, status data.AppStatus) fmt.Stringer {
+	if app.Service == nil || !app.Service.PrintCommands { return nil }
+
+	startCommand := "systemctl --user restart " + app.ServiceUnitName()
+	if !status.ServiceUnitPresent {
+		startCommand = "systemctl --user enable --now " + app.ServiceUnitName()
+	}
+	return serviceCommands{
+		appName: app.Name,
+		commands: []string{ "systemctl --user daemon-reload", startCommand },
+	}
+}
+
+// The unit (and the link systemd makes when enabling it) is deleted along with the app, so the
+// service only needs to be stopped.
+func serviceCommandsAfterRemove(app data.AppConfig, status data.AppStatus) fmt.Stringer {
+	if app.Service == nil || !app.Service.PrintCommands || !status.ServiceUnitPresent {
+		return nil
+	}
+
+	return serviceCommands{
+		appName: app.Name,
+		commands: []string{
+			"systemctl --user stop " + app.ServiceUnitName(),
+			"systemctl --user daemon-reload",
+		},
+	}
+}
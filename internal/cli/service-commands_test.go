@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/data/mocks"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceUnitIsWrittenAndRemovedWithApp(t *testing.T) {
+	systemConfig := data.DefaultTestConfig()
+
+	serviceApp := data.AppConfig{
+		SystemConfig: systemConfig,
+		Name: "syncd",
+		Flavor: data.FlavorGit,
+		RemoteRepo: run.StrPtr("doesn't matter"),
+		BuildAction: data.ActionNone,
+		Version: "main",
+		Service: &data.ServiceConfig{
+			Args: []string{ "--serve" },
+			PrintCommands: true,
+		},
+	}
+
+	mockStorage := mocks.MockManagedFiles{}
+	mockStorage.On("AppStatus", serviceApp.Name).Return(data.AppStatus{
+		IsConfigured: true,
+		SourcePresent: true,
+		TargetPresent: true,
+		LinkPresent: true,
+		ServiceUnitPresent: true,
+	})
+
+	selfmanData, err := data.SelfmanFromValues(
+		systemConfig,
+		[]data.AppConfig{ serviceApp },
+		&mockStorage,
+	)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	serviceApp = selfmanData.AppConfigs[serviceApp.Name]
+
+	actions, err := makeItSo(serviceApp.Name, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	unitPath := path.Join(*systemConfig.ServiceDir, "syncd.service")
+	assert.Equal(
+		t,
+		ops.WriteServiceUnit{
+			Unit: ops.ServiceUnit{
+				Description: "syncd",
+				ExecPath: serviceApp.BinaryPath(),
+				Args: []string{ "--serve" },
+				Restart: "on-failure",
+				WantedBy: "default.target",
+			},
+			DestinationPath: unitPath,
+			ManagedDir: systemConfig.ManagedDir(),
+		},
+		actions[len(actions) - 1],
+	)
+
+	_, appStatus := selfmanData.AppStatus(serviceApp.Name)
+	assert.Equal(
+		t,
+		serviceCommands{
+			appName: "syncd",
+			commands: []string{
+				"systemctl --user daemon-reload",
+				"systemctl --user restart syncd.service",
+			},
+		},
+		serviceCommandsAfterInstall(serviceApp, appStatus),
+	)
+
+	removeActions, err := removeApp(serviceApp.Name, false, selfmanData)
+	assert.NoError(t, err)
+	assert.Subset(t, removeActions, []ops.Operation{
+		ops.DeleteFile{ TypeOfDeletion: "Delete service unit", Path: unitPath },
+		ops.DeleteFile{
+			TypeOfDeletion: "Delete service enablement link",
+			Path: path.Join(*systemConfig.ServiceDir, "default.target.wants", "syncd.service"),
+		},
+	})
+}
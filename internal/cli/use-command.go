@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/spf13/cobra"
+)
+
+const (
+	useCmdOptionSave = "save"
+	useCmdOptionLocal = "local"
+)
+
+// How (if at all) the version chosen with the use command is remembered.
+type versionPersistence int
+const (
+	persistNothing versionPersistence = iota
+	persistToConfig
+	persistLocally
+)
+
+func CreateUseCmd() SelfmanCommand {
+	selfmanCmd := SelfmanCommand{
+		cobraCmd: &cobra.Command{
+			Use: "use [flags] app-name version",
+			Short: "Link an already-built version of an application, without fetching or building",
+			Aliases: []string{ "switch" },
+		},
+		runFunc: runUseCmd,
+	}
+
+	selfmanCmd.cobraCmd.Flags().Bool(
+		useCmdOptionSave,
+		false,
+		"Write the version into the app's config file (also clears any local override)",
+	)
+	selfmanCmd.cobraCmd.Flags().Bool(
+		useCmdOptionLocal,
+		false,
+		"Record the version as a local override, leaving the app's config file untouched",
+	)
+	addClobberFlags(selfmanCmd.cobraCmd)
+
+	return selfmanCmd
+}
+
+func runUseCmd(cmd *cobra.Command, args []string) (*SelfmanResult, error) {
+	selfmanData, err := produceSelfmanData(cmd)
+	if err != nil { return nil, err }
+
+	if len(args) < 2 {
+		return nil, fmt.Errorf(
+			"Use command expects an application name and a version, but they were not provided",
+		)
+	}
+
+	save, err := cmd.Flags().GetBool(useCmdOptionSave)
+	run.AssertNoErr(err)
+	local, err := cmd.Flags().GetBool(useCmdOptionLocal)
+	run.AssertNoErr(err)
+	if save && local {
+		return nil, fmt.Errorf(
+			"Only one of --%s and --%s may be given",
+			useCmdOptionSave, useCmdOptionLocal,
+		)
+	}
+	persistence := persistNothing
+	if save {
+		persistence = persistToConfig
+	} else if local {
+		persistence = persistLocally
+	}
+
+	clobber := clobberPolicyFromFlags(cmd, selfmanData.SystemConfig)
+	result, ops, err := useVersion(args[0], args[1], persistence, clobber, selfmanData)
+	if err != nil { return nil, err }
+
+	return &SelfmanResult{
+		textOutput: result,
+		operations: ops,
+		systemConfig: selfmanData.SystemConfig,
+	}, nil
+}
+
+type useResult struct {
+	appName string
+	version string
+	// The version in the app's config file
+	configuredVersion string
+	// The local override of the configured version in effect before this command, if any
+	overrideVersion string
+	persistence versionPersistence
+}
+
+func (self useResult) String() string {
+	if self.persistence == persistLocally && self.version != self.configuredVersion {
+		return fmt.Sprintf(
+			"Using version %s of %s (recorded locally, the config file has version %s)",
+			self.version, self.appName, self.configuredVersion,
+		)
+	}
+
+	effectiveVersion := run.CoalesceString(self.overrideVersion, self.configuredVersion)
+	if self.persistence != persistNothing || self.version == effectiveVersion {
+		return fmt.Sprintf("Using version %s of %s", self.version, self.appName)
+	}
+
+	stillConfigured := fmt.Sprintf("version %s is still configured", self.configuredVersion)
+	if len(self.overrideVersion) > 0 {
+		stillConfigured = fmt.Sprintf(
+			"version %s is still set by a local override (the config file has version %s)",
+			self.overrideVersion, self.configuredVersion,
+		)
+	}
+	return fmt.Sprintf(
+		"Using version %s of %s, but %s (make-it-so will switch back to it - use --%s or --%s " +
+			"to keep this version)",
+		self.version, self.appName, stillConfigured, useCmdOptionSave, useCmdOptionLocal,
+	)
+}
+
+// Relinks an app to a version which has already been built. The source is left alone entirely.
+func useVersion(
+	name string,
+	version string,
+	persistence versionPersistence,
+	clobber ops.ClobberPolicy,
+	selfmanData data.Selfman,
+) (useResult, []ops.Operation, error) {
+	app, appStatus := selfmanData.AppStatus(name)
+	if !appStatus.IsConfigured {
+		return useResult{}, nil,
+			fmt.Errorf("Could not find a configured application with name \"%s\"", name)
+	}
+	if app.KeepBinWithSource && version != app.Version {
+		return useResult{}, nil, fmt.Errorf(
+			"Application \"%s\" keeps its binary with its source, so only the configured " +
+				"version can be used",
+			name,
+		)
+	}
+	if !slices.Contains(appStatus.BuiltVersions, version) {
+		builtVersions := "none"
+		if len(appStatus.BuiltVersions) > 0 {
+			builtVersions = strings.Join(appStatus.BuiltVersions, ", ")
+		}
+		return useResult{}, nil, fmt.Errorf(
+			"Version %s of application \"%s\" has not been built (built versions: %s)",
+			version, name, builtVersions,
+		)
+	}
+
+	versionApp := app
+	versionApp.Version = version
+	actions := versionApp.GetLinkArtifactOps(clobber)
+
+	// git apps share a single source dir between versions, which we don't touch here
+	if app.LinkSourceAsLib && app.Flavor != data.FlavorGit {
+		if _, err := os.Stat(versionApp.SourcePath()); err == nil {
+			actions = append(actions, versionApp.GetLinkLibraryOp(clobber))
+		}
+	}
+	actions = append(actions, versionApp.GetHookOps(data.HookPostLink)...)
+
+	overrides := maps.Clone(selfmanData.VersionOverrides)
+	if overrides == nil {
+		overrides = make(map[string]string)
+	}
+	_, hadOverride := overrides[name]
+
+	switch persistence {
+	case persistToConfig: {
+		if len(app.ConfigFilePath) == 0 {
+			return useResult{}, nil,
+				fmt.Errorf("Application \"%s\" was not loaded from a config file", name)
+		}
+		contents, err := data.AppConfigFileWithVersion(app.ConfigFilePath, name, version)
+		if err != nil { return useResult{}, nil, err }
+		configStat, err := os.Stat(app.ConfigFilePath)
+		if err != nil { return useResult{}, nil, err }
+
+		actions = append(actions, ops.WriteFile{
+			TypeOfFile: "app config file",
+			Path: app.ConfigFilePath,
+			Contents: contents,
+			Mode: configStat.Mode().Perm(),
+			Overwrite: true,
+		})
+		if hadOverride {
+			delete(overrides, name)
+			actions = append(actions, versionOverridesWriteOp(selfmanData.SystemConfig, overrides))
+		}
+	}
+	case persistLocally: {
+		overrides[name] = version
+		actions = append(actions, versionOverridesWriteOp(selfmanData.SystemConfig, overrides))
+	}
+	}
+
+	result := useResult{
+		appName: name,
+		version: version,
+		configuredVersion: app.Version,
+		persistence: persistence,
+	}
+	if app.VersionIsOverridden {
+		result.configuredVersion = app.ConfigFileVersion
+		result.overrideVersion = app.Version
+	}
+	return result, actions, nil
+}
+
+func versionOverridesWriteOp(system *data.SystemConfig, overrides map[string]string) ops.Operation {
+	return ops.WriteFile{
+		TypeOfFile: "version overrides file",
+		Path: system.VersionOverridesPath(),
+		Contents: data.VersionOverridesFileContents(overrides),
+		Mode: 0o644,
+		Overwrite: true,
+	}
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/data"
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUseRelinksBuiltVersionAndRecordsChoice(t *testing.T) {
+	systemConfig := tempDirTestConfig(t)
+	configPath := path.Join(*systemConfig.AppConfigDir, "versioned.config.yaml")
+	run.AssertNoErr(os.WriteFile(
+		configPath,
+		[]byte(
+			"name: versioned\n" +
+			"flavor: git\n" +
+			"remote-repo: doesn't matter\n" +
+			"build-action: none\n" +
+			"version: v1\n",
+		),
+		0o644,
+	))
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	selfmanData, err := data.Produce(data.ConfigOverrides{ System: *systemConfig })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app := selfmanData.AppConfigs["versioned"]
+
+	touchFile(app.ArtifactPath())
+	v2App := app
+	v2App.Version = "v2"
+	touchFile(v2App.ArtifactPath())
+	run.AssertNoErr(os.Symlink(app.ArtifactPath(), app.BinaryPath()))
+
+	_, status := selfmanData.AppStatus("versioned")
+	assert.ElementsMatch(t, []string{ "v1", "v2" }, status.BuiltVersions)
+	assert.Equal(t, "v1", status.LinkedVersion)
+
+	_, _, err = useVersion("versioned", "v3", persistNothing, ops.ClobberPolicy{}, selfmanData)
+	assert.Error(t, err, "Versions which have not been built cannot be used")
+
+	result, actions, err :=
+		useVersion("versioned", "v2", persistNothing, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Equal(t, "v1", result.configuredVersion)
+	assert.Equal(
+		t,
+		v2App.GetLinkArtifactOps(ops.ClobberPolicy{}),
+		actions,
+	)
+
+	_, actions, err = useVersion("versioned", "v2", persistLocally, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	for _, action := range actions {
+		_, err := action.Execute(t.Context())
+		assert.NoError(t, err)
+	}
+
+	// the local override should now take precedence over the config file
+	selfmanData, err = data.Produce(data.ConfigOverrides{ System: *systemConfig })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app, status = selfmanData.AppStatus("versioned")
+	assert.Equal(t, "v2", app.Version)
+	assert.True(t, app.VersionIsOverridden)
+	assert.Equal(t, "v2", status.LinkedVersion)
+
+	result, _, err =
+		useVersion("versioned", "v1", persistNothing, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Equal(t, "v1", result.configuredVersion, "The config file's version is not overridden")
+	assert.Equal(t, "v2", result.overrideVersion)
+	assert.Contains(
+		t,
+		result.String(),
+		"version v2 is still set by a local override (the config file has version v1)",
+	)
+
+	// saving to the config file clears the override
+	_, actions, err = useVersion("versioned", "v2", persistToConfig, ops.ClobberPolicy{}, selfmanData)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	for _, action := range actions {
+		_, err := action.Execute(t.Context())
+		assert.NoError(t, err)
+	}
+
+	selfmanData, err = data.Produce(data.ConfigOverrides{ System: *systemConfig })
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	app = selfmanData.AppConfigs["versioned"]
+	assert.Equal(t, "v2", app.Version)
+	assert.False(t, app.VersionIsOverridden)
+	assert.Empty(t, selfmanData.VersionOverrides)
+}
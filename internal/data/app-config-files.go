@@ -0,0 +1,328 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+	"github.com/lorentzforces/selfman/internal/run"
+	"gopkg.in/yaml.v3"
+)
+
+// In TOML files, multiple apps are specified as an array of tables with this key (since TOML does
+// not allow an array at the top level of a document).
+const tomlAppListKey = "apps"
+
+func loadAppConfigs(systemConfig *SystemConfig) ([]AppConfig, error) {
+	appConfigPath := *systemConfig.AppConfigDir
+	stat, err := os.Stat(appConfigPath)
+	if err != nil {
+		// if the directory just doesn't exist, we say "okay" and return an empty list
+		if errors.Is(err, os.ErrNotExist) {
+			return make([]AppConfig, 0), nil
+		}
+		return nil, fmt.Errorf(
+			"Could not load configured application config path at \"%s\": %w",
+			appConfigPath, err,
+		)
+	}
+	if !stat.IsDir() {
+		return nil, fmt.Errorf(
+			"Configured application config path was not a directory: \"%s\"",
+			appConfigPath,
+		)
+	}
+
+	appConfigPaths := make([]string, 0)
+	entries, err := os.ReadDir(appConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Could not read configured application config path at \"%s\": %w",
+			appConfigPath, err,
+		)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsDir() {
+			continue
+		}
+		if isAppConfigFileName(entry.Name()) {
+			fullPath := path.Join(appConfigPath, entry.Name())
+			appConfigPaths = append(appConfigPaths, fullPath)
+		}
+	}
+
+	appConfigs := make([]AppConfig, 0, len(appConfigPaths))
+	// app name -> path of the file the app was first found in
+	appSources := make(map[string]string, len(appConfigPaths))
+	for _, path := range appConfigPaths {
+		fileAppConfigs, err := parseAppConfigFile(path)
+		if err != nil { return nil, err }
+
+		for _, appConfig := range fileAppConfigs {
+			if firstPath, present := appSources[appConfig.Name]; present {
+				return nil, fmt.Errorf(
+					"Application \"%s\" is configured more than once (in \"%s\" and \"%s\")",
+					appConfig.Name, firstPath, path,
+				)
+			}
+			appSources[appConfig.Name] = path
+
+			appConfig.SystemConfig = systemConfig
+			appConfig.ConfigFilePath = path
+			appConfigs = append(appConfigs, appConfig)
+		}
+	}
+
+	return appConfigs, nil
+}
+
+var appConfigRegex = regexp.MustCompile(`.+\.config\.(yaml|yml|toml|json)\z`)
+
+func isAppConfigFileName(fileName string) bool {
+	return appConfigRegex.MatchString(fileName)
+}
+
+// Parses all application configs from a single file. The format of the file is determined by its
+// extension:
+//   - YAML: a single app, a list of apps, or multiple documents containing either of those
+//   - TOML: a single app, or a list of apps in an "apps" array of tables
+//   - JSON: a single app, or a list of apps
+func parseAppConfigFile(appConfigPath string) ([]AppConfig, error) {
+	contents, err := os.ReadFile(appConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Could not read application config file \"%s\": %w",
+			appConfigPath, err,
+		)
+	}
+
+	var appConfigs []AppConfig
+	switch path.Ext(appConfigPath) {
+	case ".toml": appConfigs, err = parseTomlAppConfigs(contents)
+	case ".json": appConfigs, err = parseJsonAppConfigs(contents)
+	default: appConfigs, err = parseYamlAppConfigs(contents)
+	}
+	if err != nil {
+		newErr := fmt.Errorf(
+			"Error parsing application config file \"%s\"",
+			appConfigPath,
+		)
+
+		return nil, errors.Join(newErr, err)
+	}
+
+	return appConfigs, nil
+}
+
+func parseYamlAppConfigs(contents []byte) ([]AppConfig, error) {
+	// A first pass determines what kind of value each document holds. The second pass decodes from
+	// the original contents so that errors reference the correct lines.
+	documentKinds := make([]yaml.Kind, 0, 1)
+	nodeDecoder := yaml.NewDecoder(bytes.NewReader(contents))
+	for {
+		document := yaml.Node{}
+		err := nodeDecoder.Decode(&document)
+		if errors.Is(err, io.EOF) { break }
+		if err != nil { return nil, err }
+
+		// an empty document (such as a trailing "---") has no content, or null content
+		if len(document.Content) == 0 || document.Content[0].ShortTag() == "!!null" {
+			documentKinds = append(documentKinds, 0)
+			continue
+		}
+
+		contentNode := document.Content[0]
+		if contentNode.Kind != yaml.SequenceNode && contentNode.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf(
+				"Expected an application config or a list of application configs (line %d)",
+				contentNode.Line,
+			)
+		}
+		documentKinds = append(documentKinds, contentNode.Kind)
+	}
+
+	appConfigs := make([]AppConfig, 0, len(documentKinds))
+	decoder := run.GetStrictDecoder(bytes.NewReader(contents))
+	for _, kind := range documentKinds {
+		documentConfigs, err := decodeAppConfigDocument(decoder, kind)
+		if err != nil { return nil, err }
+		appConfigs = append(appConfigs, documentConfigs...)
+	}
+
+	return appConfigs, nil
+}
+
+func parseTomlAppConfigs(contents []byte) ([]AppConfig, error) {
+	document := make(map[string]any)
+	err := toml.Unmarshal(contents, &document)
+	if err != nil { return nil, err }
+
+	appList, isList := document[tomlAppListKey]
+	if !isList {
+		return decodeGenericAppConfigs(document)
+	}
+	if len(document) > 1 {
+		return nil, fmt.Errorf(
+			"A TOML config file with an \"%s\" list cannot contain any other top-level keys",
+			tomlAppListKey,
+		)
+	}
+	return decodeGenericAppConfigs(appList)
+}
+
+func parseJsonAppConfigs(contents []byte) ([]AppConfig, error) {
+	var document any
+	err := json.Unmarshal(contents, &document)
+	if err != nil { return nil, err }
+	return decodeGenericAppConfigs(document)
+}
+
+// Decodes generically-parsed (e.g. from TOML or JSON) config data. This round-trips the data
+// through YAML so that all formats share the same field names and strict decoding rules.
+func decodeGenericAppConfigs(document any) ([]AppConfig, error) {
+	node := yaml.Node{}
+	err := node.Encode(document)
+	run.AssertNoErrReason(err, "generically-parsed config data could not be encoded as YAML")
+	return decodeAppConfigNode(&node)
+}
+
+func decodeAppConfigNode(node *yaml.Node) ([]AppConfig, error) {
+	// yaml.Node.Decode does not support rejecting unknown fields, so we re-serialize the node and
+	// run it through our strict decoder
+	nodeBytes, err := yaml.Marshal(node)
+	run.AssertNoErrReason(err, "parsed YAML node could not be re-serialized")
+
+	if node.Kind != yaml.SequenceNode && node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("Expected an application config or a list of application configs")
+	}
+	return decodeAppConfigDocument(run.GetStrictDecoder(bytes.NewReader(nodeBytes)), node.Kind)
+}
+
+// Decodes the next document from the decoder, which is expected to hold the given kind of value.
+// Documents with no content produce no app configs.
+func decodeAppConfigDocument(decoder *yaml.Decoder, kind yaml.Kind) ([]AppConfig, error) {
+	switch kind {
+	case yaml.SequenceNode: {
+		appConfigs := make([]AppConfig, 0)
+		err := decoder.Decode(&appConfigs)
+		if err != nil { return nil, err }
+		return appConfigs, nil
+	}
+	case yaml.MappingNode: {
+		appConfig := AppConfig{}
+		err := decoder.Decode(&appConfig)
+		if err != nil { return nil, err }
+		return []AppConfig{ appConfig }, nil
+	}
+	default: {
+		// still need to consume the empty document
+		var ignored any
+		err := decoder.Decode(&ignored)
+		if err != nil { return nil, err }
+		return nil, nil
+	}
+	}
+}
+
+// Serializes the given app config for writing to an app config file. Only fields which are
+// relevant to the app are included.
+func AppConfigFileContents(appConfig AppConfig) string {
+	contents, err := yaml.Marshal(appConfig)
+	run.AssertNoErrReason(err, "app config could not be serialized")
+	return string(contents)
+}
+
+// Produces new contents for a YAML app config file, with the version of the given app changed.
+// Other apps in the file (and comments) are preserved, although formatting may not be.
+func AppConfigFileWithVersion(configPath string, appName string, version string) (string, error) {
+	if ext := path.Ext(configPath); ext != ".yaml" && ext != ".yml" {
+		return "", fmt.Errorf(
+			"Only YAML app config files can be updated by selfman, \"%s\" must be edited by hand",
+			configPath,
+		)
+	}
+
+	contents, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("Could not read application config file \"%s\": %w", configPath, err)
+	}
+
+	documents := make([]*yaml.Node, 0, 1)
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	for {
+		document := yaml.Node{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) { break }
+		if err != nil {
+			return "", fmt.Errorf("Error parsing application config file \"%s\": %w", configPath, err)
+		}
+		documents = append(documents, &document)
+	}
+
+	appNode := findAppConfigNode(documents, appName)
+	if appNode == nil {
+		return "", fmt.Errorf(
+			"Could not find application \"%s\" in config file \"%s\"",
+			appName, configPath,
+		)
+	}
+	setMappingValue(appNode, "version", version)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if len(document.Content) == 0 || document.Content[0].ShortTag() == "!!null" { continue }
+		err := encoder.Encode(document)
+		run.AssertNoErrReason(err, "parsed YAML node could not be re-serialized")
+	}
+	run.AssertNoErr(encoder.Close())
+
+	return buf.String(), nil
+}
+
+// Finds the mapping node for the app with the given name, whether it is the content of a
+// document or an item in a list.
+func findAppConfigNode(documents []*yaml.Node, appName string) *yaml.Node {
+	for _, document := range documents {
+		if len(document.Content) == 0 { continue }
+
+		candidates := []*yaml.Node{ document.Content[0] }
+		if document.Content[0].Kind == yaml.SequenceNode {
+			candidates = document.Content[0].Content
+		}
+
+		for _, candidate := range candidates {
+			if candidate.Kind != yaml.MappingNode { continue }
+			nameNode := mappingValue(candidate, "name")
+			if nameNode != nil && nameNode.Value == appName { return candidate }
+		}
+	}
+	return nil
+}
+
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i + 1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key { return mapping.Content[i + 1] }
+	}
+	return nil
+}
+
+func setMappingValue(mapping *yaml.Node, key string, value string) {
+	if valueNode := mappingValue(mapping, key); valueNode != nil {
+		valueNode.SetString(value)
+		return
+	}
+
+	keyNode := &yaml.Node{}
+	keyNode.SetString(key)
+	valueNode := &yaml.Node{}
+	valueNode.SetString(value)
+	mapping.Content = append(mapping.Content, keyNode, valueNode)
+}
@@ -0,0 +1,163 @@
+package data
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestAppConfigs(t *testing.T, files map[string]string) *SystemConfig {
+	appConfigDir := t.TempDir()
+	for fileName, contents := range files {
+		err := os.WriteFile(path.Join(appConfigDir, fileName), []byte(contents), 0o644)
+		run.AssertNoErr(err)
+	}
+
+	systemConfig := DefaultTestConfig()
+	systemConfig.AppConfigDir = &appConfigDir
+	return systemConfig
+}
+
+func appNames(appConfigs []AppConfig) []string {
+	names := make([]string, 0, len(appConfigs))
+	for _, app := range appConfigs {
+		names = append(names, app.Name)
+	}
+	return names
+}
+
+func TestLoadAppConfigsSupportsListsAndMultipleDocuments(t *testing.T) {
+	systemConfig := writeTestAppConfigs(t, map[string]string{
+		"single.config.yaml":
+			"name: single\n" +
+			"flavor: git\n",
+		"listed.config.yaml":
+			"- name: listed-one\n" +
+			"  flavor: git\n" +
+			"- name: listed-two\n" +
+			"  flavor: git\n",
+		"documents.config.yml":
+			"name: document-one\n" +
+			"flavor: git\n" +
+			"---\n" +
+			"- name: document-two\n" +
+			"  flavor: git\n" +
+			"---\n",
+	})
+
+	appConfigs, err := loadAppConfigs(systemConfig)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	assert.ElementsMatch(
+		t,
+		[]string{ "single", "listed-one", "listed-two", "document-one", "document-two" },
+		appNames(appConfigs),
+	)
+	for _, app := range appConfigs {
+		assert.Equal(t, systemConfig, app.SystemConfig)
+	}
+}
+
+func TestLoadAppConfigsSupportsTomlAndJson(t *testing.T) {
+	systemConfig := writeTestAppConfigs(t, map[string]string{
+		"single.config.toml":
+			"name = \"toml-single\"\n" +
+			"flavor = \"git\"\n" +
+			"build-target = \"bin/toml-single\"\n",
+		"listed.config.toml":
+			"[[apps]]\n" +
+			"name = \"toml-one\"\n" +
+			"flavor = \"git\"\n" +
+			"[[apps]]\n" +
+			"name = \"toml-two\"\n" +
+			"flavor = \"web-fetch\"\n" +
+			"[apps.misc-vars]\n" +
+			"ARCH = \"x86_64\"\n",
+		"single.config.json": `{ "name": "json-single", "flavor": "git" }`,
+		"listed.config.json": `[ { "name": "json-one" }, { "name": "json-two" } ]`,
+	})
+
+	appConfigs, err := loadAppConfigs(systemConfig)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	assert.ElementsMatch(
+		t,
+		[]string{ "toml-single", "toml-one", "toml-two", "json-single", "json-one", "json-two" },
+		appNames(appConfigs),
+	)
+	for _, app := range appConfigs {
+		switch app.Name {
+		case "toml-single": assert.Equal(t, "bin/toml-single", app.BuildTarget)
+		case "toml-two": assert.Equal(t, map[string]string{ "ARCH": "x86_64" }, app.MiscVars)
+		}
+	}
+}
+
+func TestLoadAppConfigsRejectsUnknownFieldsInAllFormats(t *testing.T) {
+	files := map[string]string{
+		"app.config.yaml": "name: app\nnot-a-field: true\n",
+		"app.config.toml": "name = \"app\"\nnot-a-field = true\n",
+		"app.config.json": `{ "name": "app", "not-a-field": true }`,
+	}
+
+	for fileName, contents := range files {
+		systemConfig := writeTestAppConfigs(t, map[string]string{ fileName: contents })
+		_, err := loadAppConfigs(systemConfig)
+		assert.Error(t, err, "Unknown field must be rejected in %s", fileName)
+	}
+}
+
+func TestLoadAppConfigsRejectsDuplicateNames(t *testing.T) {
+	systemConfig := writeTestAppConfigs(t, map[string]string{
+		"first.config.yaml": "name: duplicated\nflavor: git\n",
+		"second.config.json": `{ "name": "duplicated", "flavor": "git" }`,
+	})
+
+	_, err := loadAppConfigs(systemConfig)
+	assert.ErrorContains(t, err, "\"duplicated\" is configured more than once")
+}
+
+func TestAppConfigFileWithVersionOnlyChangesTargetApp(t *testing.T) {
+	systemConfig := writeTestAppConfigs(t, map[string]string{
+		"listed.config.yaml":
+			"# tools I build myself\n" +
+			"- name: listed-one\n" +
+			"  flavor: git\n" +
+			"  version: main\n" +
+			"- name: listed-two\n" +
+			"  flavor: git\n" +
+			"  version: main\n",
+		"listed.config.toml":
+			"name = \"toml-app\"\n" +
+			"flavor = \"git\"\n",
+	})
+	configPath := path.Join(*systemConfig.AppConfigDir, "listed.config.yaml")
+
+	contents, err := AppConfigFileWithVersion(configPath, "listed-two", "v2.0")
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Contains(t, contents, "# tools I build myself")
+
+	run.AssertNoErr(os.WriteFile(configPath, []byte(contents), 0o644))
+	appConfigs, err := parseAppConfigFile(configPath)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Len(t, appConfigs, 2)
+	assert.Equal(t, "main", appConfigs[0].Version)
+	assert.Equal(t, "v2.0", appConfigs[1].Version)
+
+	_, err = AppConfigFileWithVersion(configPath, "not-in-file", "v2.0")
+	assert.Error(t, err)
+
+	_, err = AppConfigFileWithVersion(
+		path.Join(*systemConfig.AppConfigDir, "listed.config.toml"),
+		"toml-app",
+		"v2.0",
+	)
+	assert.Error(t, err, "Non-YAML config files are not rewritten")
+}
@@ -3,10 +3,14 @@ package data
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/lorentzforces/selfman/internal/ops"
 	"github.com/lorentzforces/selfman/internal/run"
@@ -15,11 +19,17 @@ import (
 const (
 	FlavorGit = "git"
 	FlavorWebFetch = "web-fetch"
-	// TODO: binaryFile
+	// A pre-existing binary brought under selfman's management. The artifact is the app's only
+	// file, so there is no source to obtain or build.
+	FlavorBinaryFile = "binary-file"
 
 	ActionNone = "none"
 
 	BuildActionScript = "script"
+	BuildActionGo = "go"
+	BuildActionCargo = "cargo"
+	BuildActionMake = "make"
+	BuildActionCmake = "cmake"
 
 	UpdateActionGitFetch = "git-fetch"
 )
@@ -32,13 +42,84 @@ type AppConfig struct {
 	Flavor string
 	Version string
 	BuildAction string `yaml:"build-action"`
-	BuildTarget string `yaml:"build-target"`
+	BuildTarget string `yaml:"build-target,omitempty"`
+	// For builds which produce more than one binary (cannot be combined with build-target)
+	BuildTargets []BuildTargetConfig `yaml:"build-targets,omitempty"`
+	// If set, the build target is a directory (kept whole as the artifact) and this is the path of
+	// the executable within it which gets linked
+	Entrypoint string `yaml:"entrypoint,omitempty"`
 	RemoteRepo *string `yaml:"remote-repo,omitempty"`
 	BuildCmd *string `yaml:"build-cmd,omitempty"`
 	WebUrl *string `yaml:"web-url,omitempty"`
-	KeepBinWithSource bool `yaml:"keep-bin-with-source"`
-	LinkSourceAsLib bool `yaml:"link-source-as-lib"`
-	MiscVars map[string]string `yaml:"misc-vars"`
+	// Override the system network settings for this app's clones, fetches, and downloads
+	NetworkTimeout string `yaml:"network-timeout,omitempty"`
+	NetworkRetries *int `yaml:"network-retries,omitempty"`
+	NetworkBackoff string `yaml:"network-backoff,omitempty"`
+	// Environment variables set for the app's build
+	Env map[string]string `yaml:"env,omitempty"`
+	// Dirs put at the front of PATH for the app's build
+	PathPrepend []string `yaml:"path-prepend,omitempty"`
+	// Build with a minimal environment (plus env and path-prepend) instead of selfman's own
+	CleanEnv bool `yaml:"clean-env,omitempty"`
+	// If set, the build is stopped after running this long (e.g. "20m")
+	BuildTimeout string `yaml:"build-timeout,omitempty"`
+	// Run with the script shell after each build, before the new artifact is linked. %ARTIFACT% is
+	// replaced with the path of the artifact (e.g. "%ARTIFACT% --version").
+	VerifyCmd string `yaml:"verify-cmd,omitempty"`
+	// If set, the verify command's output must match this regex, in which ^ and $ match at line
+	// boundaries (placeholders such as %VERSION% are matched literally)
+	VerifyOutput string `yaml:"verify-output,omitempty"`
+	// Commands run before and after the app is built, after it is linked, and before it is removed
+	Hooks HooksConfig `yaml:"hooks,omitempty"`
+	KeepBinWithSource bool `yaml:"keep-bin-with-source,omitempty"`
+	LinkSourceAsLib bool `yaml:"link-source-as-lib,omitempty"`
+	// Also link each built version side-by-side as "name@version" in the binary dir
+	LinkVersions bool `yaml:"link-versions,omitempty"`
+	MiscVars map[string]string `yaml:"misc-vars,omitempty"`
+	// Overrides the system link mode for this app
+	LinkMode string `yaml:"link-mode,omitempty"`
+	// If set, a wrapper script is placed at the binary path instead of a symlink
+	Wrapper *WrapperConfig `yaml:"wrapper,omitempty"`
+	// Man pages, shell completions, and other files to install alongside the app
+	ExtraFileConfigs []ExtraFileConfig `yaml:"extra-files,omitempty"`
+	// If set, a desktop entry is written so the app shows up in desktop launchers
+	DesktopEntry *DesktopEntryConfig `yaml:"desktop-entry,omitempty"`
+	// If set, a systemd user unit is written to run the app as a service
+	Service *ServiceConfig `yaml:"service,omitempty"`
+	// The file this app's config was loaded from, if it was loaded from a file
+	ConfigFilePath string `yaml:"-"`
+	// Whether Version comes from a local override rather than the app's config file
+	VersionIsOverridden bool `yaml:"-"`
+	// The version set in the app's config file, which may differ from Version if it is overridden
+	ConfigFileVersion string `yaml:"-"`
+}
+
+type BuildTargetConfig struct {
+	// Path of the built file, relative to the source dir
+	Path string `yaml:"path"`
+	// Name of the link for this target in the binary dir (defaults to the file name of the path)
+	LinkName string `yaml:"link-name,omitempty"`
+}
+
+// One of the files an app's build produces, and the places selfman puts it.
+type AppArtifact struct {
+	LinkName string
+	BuildTargetPath string
+	ArtifactPath string
+	// The file the app's link points to (inside ArtifactPath, for directory artifacts)
+	LinkTargetPath string
+	BinaryPath string
+}
+
+type WrapperConfig struct {
+	// Environment variables to set (values are used literally, without shell expansion)
+	Env map[string]string `yaml:"env,omitempty"`
+	// Directory to run the app from
+	WorkingDir string `yaml:"working-dir,omitempty"`
+	// Run the app from its source directory (cannot be combined with working-dir)
+	RunInSourceDir bool `yaml:"run-in-source-dir,omitempty"`
+	// Arguments always passed to the app, ahead of any others
+	Args []string `yaml:"args,omitempty"`
 }
 
 func (self *AppConfig) SourcePath() string {
@@ -48,35 +129,273 @@ func (self *AppConfig) SourcePath() string {
 	return path.Join(self.SystemConfig.SourcesPath(), self.Name, self.Version)
 }
 
+// Log files are shared across all of an app's versions.
+func (self *AppConfig) LogsPath() string {
+	return path.Join(self.SystemConfig.LogsPath(), self.Name)
+}
+
 // Will replace the path separator if it is found in the version (e.g. "origin/main")
+//
+// For apps with multiple build targets, this is a directory holding each of the app's artifacts.
 func (self *AppConfig) ArtifactPath() string {
 	if self.KeepBinWithSource {
 		return self.BuildTargetPath()
 	}
 
-	rawFileName := self.Name + "---" + self.Version
-	escapedFileName := strings.ReplaceAll(rawFileName, string(os.PathSeparator), "%SLASH%")
-	return path.Join(self.SystemConfig.ArtifactsPath(), escapedFileName)
+	fileName := self.ArtifactFilePrefix() + escapeVersion(self.Version)
+	return path.Join(self.SystemConfig.ArtifactsPath(), fileName)
+}
+
+// Where the app's build is verified before it is moved to ArtifactPath. Kept outside the artifacts
+// dir, so that an unverified build is never mistaken for a built version.
+func (self *AppConfig) StagingPath() string {
+	fileName := self.ArtifactFilePrefix() + escapeVersion(self.Version)
+	return path.Join(self.SystemConfig.StagingPath(), fileName)
+}
+
+// Where a path within the app's artifact is found while the artifact is staged.
+func (self *AppConfig) stagedPath(artifactPath string) string {
+	relPath, err := filepath.Rel(self.ArtifactPath(), artifactPath)
+	run.AssertNoErr(err)
+	return path.Join(self.StagingPath(), relPath)
+}
+
+const slashEscape = "%SLASH%"
+
+// Version labels may contain path separators (e.g. "origin/main"), which must be escaped when the
+// version is used as part of a file name.
+func escapeVersion(version string) string {
+	return strings.ReplaceAll(version, string(os.PathSeparator), slashEscape)
+}
+
+func unescapeVersion(escapedVersion string) string {
+	return strings.ReplaceAll(escapedVersion, slashEscape, string(os.PathSeparator))
+}
+
+// The executable within the artifact for apps with an entrypoint, otherwise the artifact itself.
+func (self *AppConfig) EntrypointPath() string {
+	if len(self.Entrypoint) == 0 { return self.ArtifactPath() }
+	return path.Join(self.ArtifactPath(), self.Entrypoint)
+}
+
+// The file the app's link points to, or for apps with build-targets the first target's file.
+func (self *AppConfig) PrimaryArtifactPath() string {
+	return self.Artifacts()[0].LinkTargetPath
+}
+
+// All artifacts for this app (regardless of version) have file names starting with this prefix.
+func (self *AppConfig) ArtifactFilePrefix() string {
+	return self.Name + "---"
+}
+
+// The reverse of ArtifactPath: given the name of a file in the artifacts dir, returns the version
+// of this app it is an artifact for (if any).
+func (self *AppConfig) ArtifactVersion(artifactFileName string) (string, bool) {
+	if !strings.HasPrefix(artifactFileName, self.ArtifactFilePrefix()) { return "", false }
+	return unescapeVersion(strings.TrimPrefix(artifactFileName, self.ArtifactFilePrefix())), true
 }
 
 func (self *AppConfig) BuildTargetPath() string {
 	return path.Join(self.SourcePath(), self.BuildTarget)
 }
 
+// Every artifact the app's build produces. Apps with a single build target have exactly one
+// artifact, linked under the app's name.
+func (self *AppConfig) Artifacts() []AppArtifact {
+	if len(self.BuildTargets) == 0 {
+		return []AppArtifact{
+			{
+				LinkName: self.Name,
+				BuildTargetPath: self.BuildTargetPath(),
+				ArtifactPath: self.ArtifactPath(),
+				LinkTargetPath: self.EntrypointPath(),
+				BinaryPath: self.BinaryPath(),
+			},
+		}
+	}
+
+	artifacts := make([]AppArtifact, 0, len(self.BuildTargets))
+	for _, target := range self.BuildTargets {
+		linkName := target.linkName()
+		artifactPath := path.Join(self.ArtifactPath(), linkName)
+		artifacts = append(artifacts, AppArtifact{
+			LinkName: linkName,
+			BuildTargetPath: path.Join(self.SourcePath(), target.Path),
+			ArtifactPath: artifactPath,
+			LinkTargetPath: artifactPath,
+			BinaryPath: path.Join(*self.SystemConfig.BinaryDir, linkName),
+		})
+	}
+	return artifacts
+}
+
+func (self BuildTargetConfig) linkName() string {
+	if len(self.LinkName) > 0 { return self.LinkName }
+	return path.Base(self.Path)
+}
+
 func (self *AppConfig) BinaryPath() string {
 	return path.Join(*self.SystemConfig.BinaryDir, self.Name)
 }
 
+// The side-by-side link for a specific version of this app, used if LinkVersions is set.
+func (self *AppConfig) VersionedBinaryPath(version string) string {
+	return path.Join(*self.SystemConfig.BinaryDir, self.versionedLinkPrefix() + escapeVersion(version))
+}
+
+func (self *AppConfig) versionedLinkPrefix() string {
+	return self.Name + "@"
+}
+
+// The reverse of VersionedBinaryPath: given the name of a file in the binary dir, returns the
+// version of this app it would be the versioned link for (if any).
+func (self *AppConfig) VersionedLinkVersion(linkFileName string) (string, bool) {
+	if !strings.HasPrefix(linkFileName, self.versionedLinkPrefix()) { return "", false }
+	return unescapeVersion(strings.TrimPrefix(linkFileName, self.versionedLinkPrefix())), true
+}
+
 func (self *AppConfig) LibPath() string {
 	return path.Join(*self.SystemConfig.LibDir, self.Name)
 }
 
+// Whether selfman is able to obtain this app's source itself. If not, the app can only be linked
+// from an artifact which is already present.
+func (self *AppConfig) CanObtainSource() bool {
+	return self.Flavor != FlavorBinaryFile
+}
+
+// Moves each built target into place as an artifact. Apps with a verify command have their
+// targets verified at the staging path first, and only replace the artifact if they pass.
+func (self *AppConfig) GetMoveTargetOps() []ops.Operation {
+	moveOps := make([]ops.Operation, 0, 1)
+	if len(self.VerifyCmd) == 0 {
+		for _, artifact := range self.Artifacts() {
+			moveOps = append(moveOps, ops.MoveTarget{
+				SourcePath: artifact.BuildTargetPath,
+				DestinationPath: artifact.ArtifactPath,
+			})
+		}
+		return moveOps
+	}
+
+	for _, artifact := range self.Artifacts() {
+		moveOps = append(moveOps, ops.MoveTarget{
+			SourcePath: artifact.BuildTargetPath,
+			DestinationPath: self.stagedPath(artifact.ArtifactPath),
+		})
+	}
+	for _, artifact := range self.Artifacts() {
+		moveOps = append(moveOps, ops.VerifyArtifact{
+			ArtifactPath: self.stagedPath(artifact.LinkTargetPath),
+			DiscardPath: self.StagingPath(),
+			ScriptShell: *self.SystemConfig.ScriptShell,
+			Cmd: self.VerifyCmd,
+			ExpectedOutput: self.VerifyOutput,
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
+		})
+	}
+	// all of the app's targets are swapped in at once, once every one of them has passed
+	moveOps = append(moveOps, ops.MoveTarget{
+		SourcePath: self.StagingPath(),
+		DestinationPath: self.ArtifactPath(),
+	})
+	return moveOps
+}
+
+// Links each of the app's artifacts into the binary dir.
+func (self *AppConfig) GetLinkArtifactOps(clobber ops.ClobberPolicy) []ops.Operation {
+	linkOps := make([]ops.Operation, 0, 1)
+	for _, artifact := range self.Artifacts() {
+		linkOps = append(linkOps, self.GetLinkOp(artifact, clobber))
+	}
+	return linkOps
+}
+
+func (self *AppConfig) GetLinkOp(artifact AppArtifact, clobber ops.ClobberPolicy) ops.Operation {
+	return self.binaryLinkOp(artifact.LinkTargetPath, artifact.BinaryPath, clobber)
+}
+
+func (self *AppConfig) GetLinkVersionedArtifactOp(clobber ops.ClobberPolicy) ops.Operation {
+	return self.binaryLinkOp(self.EntrypointPath(), self.VersionedBinaryPath(self.Version), clobber)
+}
+
+// The app's link mode if it has one, otherwise the system's.
+func (self *AppConfig) EffectiveLinkMode() string {
+	if len(self.LinkMode) > 0 { return self.LinkMode }
+	if self.SystemConfig.LinkMode != nil { return *self.SystemConfig.LinkMode }
+	return LinkModeAbsolute
+}
+
+// Apps with a wrapper config get a wrapper script at the given path, all others get a link (or
+// copy) according to their link mode. Directory artifacts are always linked, since the entrypoint
+// cannot run without the rest of its directory.
+func (self *AppConfig) binaryLinkOp(
+	artifactPath string,
+	destPath string,
+	clobber ops.ClobberPolicy,
+) ops.Operation {
+	if self.Wrapper != nil {
+		return ops.WriteWrapper{
+			Script: self.WrapperScript(),
+			DestinationPath: destPath,
+			ManagedDir: self.SystemConfig.ManagedDir(),
+			Clobber: clobber,
+		}
+	}
+
+	if self.EffectiveLinkMode() == LinkModeCopy && len(self.Entrypoint) == 0 {
+		return ops.CopyArtifact{
+			SourcePath: artifactPath,
+			DestinationPath: destPath,
+			ManagedDir: self.SystemConfig.ManagedDir(),
+			Clobber: clobber,
+		}
+	}
+
+	return ops.LinkArtifact{
+		SourcePath: artifactPath,
+		DestinationPath: destPath,
+		ManagedDir: self.SystemConfig.ManagedDir(),
+		Clobber: clobber,
+		Relative: self.EffectiveLinkMode() == LinkModeRelative,
+	}
+}
+
+// Only meaningful for apps with a wrapper config.
+func (self *AppConfig) WrapperScript() ops.WrapperScript {
+	script := ops.WrapperScript{
+		ArtifactPath: self.EntrypointPath(),
+		Env: self.Wrapper.Env,
+		WorkingDir: self.Wrapper.WorkingDir,
+		Args: self.Wrapper.Args,
+	}
+	if self.Wrapper.RunInSourceDir {
+		script.WorkingDir = self.SourcePath()
+	}
+	return script
+}
+
+// Library dirs are always linked, even in copy mode.
+func (self *AppConfig) GetLinkLibraryOp(clobber ops.ClobberPolicy) ops.Operation {
+	return ops.LinkLibrary{
+		SourcePath: self.SourcePath(),
+		DestinationPath: self.LibPath(),
+		ManagedDir: self.SystemConfig.ManagedDir(),
+		Relative: self.EffectiveLinkMode() == LinkModeRelative,
+		Clobber: clobber,
+	}
+}
+
 func (self *AppConfig) GetObtainSourceOp() ops.Operation {
 	switch self.Flavor{
 	case FlavorGit: {
 		return ops.GitClone{
 			RepoUrl: *self.RemoteRepo,
 			DestinationPath: self.SourcePath(),
+			Network: self.NetworkPolicy(),
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
 		}
 	}
 	case FlavorWebFetch: {
@@ -84,6 +403,7 @@ func (self *AppConfig) GetObtainSourceOp() ops.Operation {
 			SourceUrl: *self.WebUrl,
 			Version: self.Version,
 			DestinationDir: self.SourcePath(),
+			Network: self.NetworkPolicy(),
 		}
 	}
 	}
@@ -95,6 +415,22 @@ func (self *AppConfig) GetObtainSourceOp() ops.Operation {
 	panic("Unreachable in theory")
 }
 
+func (self *AppConfig) BuildEnv() run.Env {
+	return run.Env{
+		Vars: self.Env,
+		PathPrepend: self.PathPrepend,
+		Clean: self.CleanEnv,
+	}
+}
+
+// Zero if the build has no timeout. The timeout is validated when the app's config is loaded.
+func (self *AppConfig) buildTimeout() time.Duration {
+	if len(self.BuildTimeout) == 0 { return 0 }
+	timeout, err := time.ParseDuration(self.BuildTimeout)
+	run.AssertNoErr(err)
+	return timeout
+}
+
 func (self *AppConfig) GetBuildOp() ops.Operation {
 	switch self.BuildAction {
 	case ActionNone: {
@@ -105,6 +441,47 @@ func (self *AppConfig) GetBuildOp() ops.Operation {
 			SourcePath: self.SourcePath(),
 			ScriptShell: *self.SystemConfig.ScriptShell,
 			ScriptCmd: *self.BuildCmd,
+			Env: self.BuildEnv(),
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
+			Timeout: self.buildTimeout(),
+		}
+	}
+	case BuildActionGo: {
+		return ops.BuildWithGo{
+			SourcePath: self.SourcePath(),
+			OutputPath: self.BuildTargetPath(),
+			Env: self.BuildEnv(),
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
+			Timeout: self.buildTimeout(),
+		}
+	}
+	case BuildActionCargo: {
+		return ops.BuildWithCargo{
+			SourcePath: self.SourcePath(),
+			Env: self.BuildEnv(),
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
+			Timeout: self.buildTimeout(),
+		}
+	}
+	case BuildActionMake: {
+		return ops.BuildWithMake{
+			SourcePath: self.SourcePath(),
+			Env: self.BuildEnv(),
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
+			Timeout: self.buildTimeout(),
+		}
+	}
+	case BuildActionCmake: {
+		return ops.BuildWithCmake{
+			SourcePath: self.SourcePath(),
+			Env: self.BuildEnv(),
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
+			Timeout: self.buildTimeout(),
 		}
 	}
 	}
@@ -121,6 +498,8 @@ func (self *AppConfig) GetSelectVersionOp() ops.Operation {
 		return ops.GitCheckoutRef{
 			RepoPath: self.SourcePath(),
 			RefName: self.Version,
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
 		}
 	}
 	default: { return nil }
@@ -132,6 +511,9 @@ func (self *AppConfig) GetFetchUpdatesOp() ops.Operation {
 	case FlavorGit: {
 		return ops.GitFetch{
 			RepoPath: self.SourcePath(),
+			Network: self.NetworkPolicy(),
+			LogDir: self.LogsPath(),
+			AppName: self.Name,
 		}
 	}
 	default: {
@@ -141,8 +523,8 @@ func (self *AppConfig) GetFetchUpdatesOp() ops.Operation {
 }
 
 func (self *AppConfig) applyDefaults() {
-	if len(self.BuildTarget) == 0 {
-		self.BuildTarget = strings.ToLower(self.Name)
+	if len(self.BuildTarget) == 0 && len(self.BuildTargets) == 0 {
+		self.BuildTarget = self.defaultBuildTarget()
 	}
 
 	if self.MiscVars == nil {
@@ -151,11 +533,27 @@ func (self *AppConfig) applyDefaults() {
 	self.MiscVars["VERSION"] = self.Version
 }
 
+// Where the app's build action places its binary, assuming the binary is named after the app.
+func (self *AppConfig) defaultBuildTarget() string {
+	binaryName := strings.ToLower(self.Name)
+	switch self.BuildAction {
+	case BuildActionCargo: return path.Join("target", "release", binaryName)
+	case BuildActionCmake: return path.Join("build", binaryName)
+	default: return binaryName
+	}
+}
+
 // Will apply misc vars to replace appropriate placeholders in these fields:
 //   - BuildAction
 //   - BuildTarget
+//   - BuildTargets (paths)
 //   - BuildCmd
 //   - WebUrl
+//   - Env (values)
+//   - PathPrepend
+//   - VerifyCmd (%ARTIFACT% is left in place, to be filled in for each artifact)
+//   - VerifyOutput (values are escaped, so they match literally)
+//   - Hooks
 func (self *AppConfig) applyMiscVarsToPlaceholders() error {
 	var err error
 	self.BuildAction, err = replacePlaceholders(self.BuildAction, self.MiscVars)
@@ -166,6 +564,17 @@ func (self *AppConfig) applyMiscVarsToPlaceholders() error {
 	if err != nil {
 		return errors.Join(fmt.Errorf("Error filling placeholders in BuildTarget"), err)
 	}
+	if len(self.BuildTargets) > 0 {
+		// don't modify the (possibly shared) original list
+		self.BuildTargets = slices.Clone(self.BuildTargets)
+		for i := range self.BuildTargets {
+			self.BuildTargets[i].Path, err =
+				replacePlaceholders(self.BuildTargets[i].Path, self.MiscVars)
+			if err != nil {
+				return errors.Join(fmt.Errorf("Error filling placeholders in BuildTargets"), err)
+			}
+		}
+	}
 	if self.BuildCmd != nil {
 		*self.BuildCmd, err = replacePlaceholders(*self.BuildCmd, self.MiscVars)
 		if err != nil {
@@ -178,6 +587,47 @@ func (self *AppConfig) applyMiscVarsToPlaceholders() error {
 			return errors.Join(fmt.Errorf("Error filling placeholders in WebUrl"), err)
 		}
 	}
+	if len(self.Env) > 0 {
+		// don't modify the (possibly shared) original map
+		self.Env = maps.Clone(self.Env)
+		for name, value := range self.Env {
+			self.Env[name], err = replacePlaceholders(value, self.MiscVars)
+			if err != nil {
+				return errors.Join(fmt.Errorf("Error filling placeholders in Env"), err)
+			}
+		}
+	}
+	if len(self.PathPrepend) > 0 {
+		self.PathPrepend = slices.Clone(self.PathPrepend)
+		for i := range self.PathPrepend {
+			self.PathPrepend[i], err = replacePlaceholders(self.PathPrepend[i], self.MiscVars)
+			if err != nil {
+				return errors.Join(fmt.Errorf("Error filling placeholders in PathPrepend"), err)
+			}
+		}
+	}
+	if len(self.VerifyCmd) > 0 {
+		verifyVars := maps.Clone(self.MiscVars)
+		verifyVars[strings.Trim(ops.ArtifactPlaceholder, "%")] = ops.ArtifactPlaceholder
+		self.VerifyCmd, err = replacePlaceholders(self.VerifyCmd, verifyVars)
+		if err != nil {
+			return errors.Join(fmt.Errorf("Error filling placeholders in VerifyCmd"), err)
+		}
+	}
+	if len(self.VerifyOutput) > 0 {
+		quotedVars := make(map[string]string, len(self.MiscVars))
+		for label, value := range self.MiscVars {
+			quotedVars[label] = regexp.QuoteMeta(value)
+		}
+		self.VerifyOutput, err = replacePlaceholders(self.VerifyOutput, quotedVars)
+		if err != nil {
+			return errors.Join(fmt.Errorf("Error filling placeholders in VerifyOutput"), err)
+		}
+	}
+	err = self.Hooks.applyPlaceholders(self.MiscVars)
+	if err != nil {
+		return errors.Join(fmt.Errorf("Error filling placeholders in Hooks"), err)
+	}
 
 	return nil
 }
@@ -235,6 +685,109 @@ func (self *AppConfig) validate() error {
 			"(app %s) Web URL must be specified for apps of flavor %s", self.Name, FlavorWebFetch)
 	}
 
+	if self.BuildAction == BuildActionScript && self.BuildCmd == nil {
+		return fmt.Errorf(
+			"(app %s) Build command must be specified for build action %s",
+			self.Name, BuildActionScript,
+		)
+	}
+
+	if self.BuildAction != BuildActionScript && self.BuildAction != ActionNone &&
+		self.BuildCmd != nil {
+		return fmt.Errorf(
+			"(app %s) Build command is only used with build action %s, not %s",
+			self.Name, BuildActionScript, self.BuildAction,
+		)
+	}
+
+	if self.BuildAction == BuildActionGo && len(self.BuildTargets) > 0 {
+		return fmt.Errorf(
+			"(app %s) Build action %s produces a single binary, and cannot be combined with " +
+				"build-targets",
+			self.Name, BuildActionGo,
+		)
+	}
+
+	if self.Flavor == FlavorBinaryFile && self.BuildAction != ActionNone {
+		return fmt.Errorf(
+			"(app %s) Build action must be \"%s\" for apps of flavor %s",
+			self.Name, ActionNone, FlavorBinaryFile,
+		)
+	}
+
+	for name := range self.Env {
+		if nil == envVarNamePattern.FindStringIndex(name) {
+			return fmt.Errorf(
+				"(app %s) Env var name \"%s\" must be only letters, digits, and underscores, " +
+					"and cannot start with a digit",
+				self.Name, name,
+			)
+		}
+	}
+
+	for _, dir := range self.PathPrepend {
+		if len(dir) == 0 || strings.ContainsRune(dir, os.PathListSeparator) {
+			return fmt.Errorf(
+				"(app %s) Invalid path-prepend entry \"%s\", must be a single non-empty dir",
+				self.Name, dir,
+			)
+		}
+	}
+
+	if len(self.BuildTimeout) > 0 {
+		timeout, err := time.ParseDuration(self.BuildTimeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf(
+				"(app %s) Invalid build-timeout \"%s\", must be a duration like \"20m\"",
+				self.Name, self.BuildTimeout,
+			)
+		}
+	}
+
+	if err := self.validateNetworkSettings(); err != nil { return err }
+
+	if err := self.validateVerifySettings(); err != nil { return err }
+
+	if err := self.validateHooks(); err != nil { return err }
+
+	if err := self.validateBuildTargets(); err != nil {
+		return err
+	}
+
+	if err := self.validateEntrypoint(); err != nil {
+		return err
+	}
+
+	if err := self.validateExtraFiles(); err != nil {
+		return err
+	}
+
+	if err := self.DesktopEntry.validate(self.Name); err != nil {
+		return err
+	}
+
+	if err := self.Service.validate(self.Name); err != nil {
+		return err
+	}
+
+	if len(self.LinkMode) > 0 && !isValidLinkMode(self.LinkMode) {
+		return fmt.Errorf(
+			"(app %s) Invalid link mode \"%s\", must be one of: %s",
+			self.Name, self.LinkMode, strings.Join(linkModes, ", "),
+		)
+	}
+
+	if err := self.Wrapper.validate(self.Name); err != nil {
+		return err
+	}
+
+	if self.LinkVersions && strings.Contains(self.Name, "@") {
+		return fmt.Errorf(
+			"(app %s) Apps with versioned links cannot have \"@\" in their name",
+			self.Name,
+		)
+	}
+
 	for label, _ := range self.MiscVars {
 		if nil == validLabelPattern.FindStringIndex(label) {
 			return fmt.Errorf(
@@ -256,85 +809,143 @@ func (self *AppConfig) validate() error {
 	return nil
 }
 
-func (self *AppConfig) isValidAppFlavor() bool {
-	switch self.Flavor {
-	case FlavorGit: return true
-	case FlavorWebFetch: return true
-	default: return false
-	}
-}
+func (self *AppConfig) validateBuildTargets() error {
+	if len(self.BuildTargets) == 0 { return nil }
 
-func (self *AppConfig) isValidBuildAction() bool {
-	switch self.BuildAction {
-	case ActionNone, BuildActionScript: return true
-	default: return false
+	if len(self.BuildTarget) > 0 {
+		return fmt.Errorf(
+			"(app %s) Only one of build-target and build-targets may be specified",
+			self.Name,
+		)
 	}
-}
 
-func loadAppConfigs(systemConfig *SystemConfig) ([]AppConfig, error) {
-	appConfigPath := *systemConfig.AppConfigDir
-	stat, err := os.Stat(appConfigPath)
-	if err != nil {
-		// if the directory just doesn't exist, we say "okay" and return an empty list
-		if errors.Is(err, os.ErrNotExist) {
-			return make([]AppConfig, 0), nil
-		}
-		return nil, fmt.Errorf(
-			"Could not load configured application config path at \"%s\": %w",
-			appConfigPath, err,
-		)
+	unsupported := ""
+	switch {
+	case self.Flavor == FlavorBinaryFile: unsupported = "flavor " + FlavorBinaryFile
+	case self.KeepBinWithSource: unsupported = "keep-bin-with-source"
+	case self.LinkVersions: unsupported = "link-versions"
+	case self.Wrapper != nil: unsupported = "wrapper"
 	}
-	if !stat.IsDir() {
-		return nil, fmt.Errorf(
-			"Configured application config path was not a directory: \"%s\"",
-			appConfigPath,
+	if len(unsupported) > 0 {
+		return fmt.Errorf(
+			"(app %s) Apps with build-targets do not support %s",
+			self.Name, unsupported,
 		)
 	}
 
-	appConfigPaths := make([]string, 0)
-	entries, err := os.ReadDir(*systemConfig.AppConfigDir)
-	for _, entry := range entries {
-		if entry.Type().IsDir() {
-			continue
+	linkNames := make(map[string]bool, len(self.BuildTargets))
+	for _, target := range self.BuildTargets {
+		if len(target.Path) == 0 {
+			return fmt.Errorf("(app %s) Every build target must have a path", self.Name)
 		}
-		if isAppConfigFileName(entry.Name()) {
-			fullPath := path.Join(appConfigPath, entry.Name())
-			appConfigPaths = append(appConfigPaths, fullPath)
+		linkName := target.linkName()
+		if strings.Contains(linkName, "/") || linkName == "." || linkName == ".." {
+			return fmt.Errorf("(app %s) Invalid link name \"%s\"", self.Name, linkName)
 		}
+		if linkNames[linkName] {
+			return fmt.Errorf(
+				"(app %s) More than one build target has the link name \"%s\"",
+				self.Name, linkName,
+			)
+		}
+		linkNames[linkName] = true
 	}
 
-	appConfigs := make([]AppConfig, 0, len(appConfigPaths))
-	for _, path := range appConfigPaths {
-		appConfig, err := parseAppConfig(path)
-		if err != nil { return nil, err }
+	return nil
+}
 
-		appConfig.SystemConfig = systemConfig
-		appConfigs = append(appConfigs, appConfig)
+func (self *AppConfig) validateVerifySettings() error {
+	if len(self.VerifyOutput) > 0 && len(self.VerifyCmd) == 0 {
+		return fmt.Errorf("(app %s) verify-output requires a verify-cmd", self.Name)
 	}
-
-	return appConfigs, nil
+	if len(self.VerifyCmd) > 0 && self.Flavor == FlavorBinaryFile {
+		return fmt.Errorf(
+			"(app %s) Apps of flavor %s are never built, so verify-cmd would never be run",
+			self.Name, FlavorBinaryFile,
+		)
+	}
+	if len(self.VerifyCmd) > 0 && self.KeepBinWithSource {
+		return fmt.Errorf(
+			"(app %s) Apps with keep-bin-with-source are built in place, so their builds " +
+				"cannot be verified before replacing the linked binary",
+			self.Name,
+		)
+	}
+	// placeholders are not special characters in a regex, so they can be checked before being
+	// filled in
+	if _, err := regexp.Compile(self.VerifyOutput); err != nil {
+		return fmt.Errorf(
+			"(app %s) Invalid verify-output \"%s\": %w",
+			self.Name, self.VerifyOutput, err,
+		)
+	}
+	return nil
 }
 
-var appConfigRegex = regexp.MustCompile(`.+\.config\.yaml\z`)
+func (self *AppConfig) validateEntrypoint() error {
+	if len(self.Entrypoint) == 0 { return nil }
+
+	if !filepath.IsLocal(self.Entrypoint) {
+		return fmt.Errorf(
+			"(app %s) Entrypoint \"%s\" must be a relative path within the build target",
+			self.Name, self.Entrypoint,
+		)
+	}
+
+	unsupported := ""
+	switch {
+	case self.Flavor == FlavorBinaryFile: unsupported = "flavor " + FlavorBinaryFile
+	case len(self.BuildTargets) > 0: unsupported = "build-targets"
+	case self.LinkMode == LinkModeCopy: unsupported = "link-mode " + LinkModeCopy
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf(
+			"(app %s) Apps with an entrypoint do not support %s",
+			self.Name, unsupported,
+		)
+	}
 
-func isAppConfigFileName(fileName string) bool {
-	return appConfigRegex.MatchString(fileName)
+	return nil
 }
 
-func parseAppConfig(appConfigPath string) (AppConfig, error) {
-	configFile, err := os.Open(appConfigPath)
-	run.AssertNoErr(err)
+var envVarNamePattern = regexp.MustCompile(`\A[A-Za-z_][A-Za-z0-9_]*\z`)
 
-	appConfig := AppConfig{}
-	err = run.GetStrictDecoder(configFile).Decode(&appConfig)
-	if err != nil {
-		newErr := fmt.Errorf(
-			"Error parsing application config file \"%s\"",
-			appConfigPath,
+func (self *WrapperConfig) validate(appName string) error {
+	if self == nil { return nil }
+
+	if self.RunInSourceDir && len(self.WorkingDir) > 0 {
+		return fmt.Errorf(
+			"(app %s) Wrapper cannot have both working-dir and run-in-source-dir set",
+			appName,
 		)
+	}
 
-		return AppConfig{}, errors.Join(newErr, err)
+	for name := range self.Env {
+		if nil == envVarNamePattern.FindStringIndex(name) {
+			return fmt.Errorf(
+				"(app %s) Wrapper env var name \"%s\" must be only letters, digits, and " +
+					"underscores, and cannot start with a digit",
+				appName, name,
+			)
+		}
 	}
 
-	return appConfig, nil
+	return nil
+}
+
+func (self *AppConfig) isValidAppFlavor() bool {
+	switch self.Flavor {
+	case FlavorGit: return true
+	case FlavorWebFetch: return true
+	case FlavorBinaryFile: return true
+	default: return false
+	}
+}
+
+func (self *AppConfig) isValidBuildAction() bool {
+	switch self.BuildAction {
+	case ActionNone, BuildActionScript: return true
+	case BuildActionGo, BuildActionCargo, BuildActionMake, BuildActionCmake: return true
+	default: return false
+	}
 }
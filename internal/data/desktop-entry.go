@@ -0,0 +1,89 @@
+package data
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+type DesktopEntryConfig struct {
+	// The name shown in launchers (defaults to the app's name)
+	Name string `yaml:"name,omitempty"`
+	Comment string `yaml:"comment,omitempty"`
+	// Path of the app's icon, relative to the source dir
+	Icon string `yaml:"icon,omitempty"`
+	Categories []string `yaml:"categories,omitempty"`
+	// Whether the app needs to be run in a terminal
+	Terminal bool `yaml:"terminal,omitempty"`
+}
+
+// Desktop entry icons are stored and installed like the app's other extra files.
+const extraFileDesktopIcon = "desktop-icon"
+
+func (self *AppConfig) DesktopEntryPath() string {
+	return path.Join(*self.SystemConfig.ShareDir, "applications", self.Name + ".desktop")
+}
+
+func (self *AppConfig) desktopIconConfig() (ExtraFileConfig, bool) {
+	if self.DesktopEntry == nil || len(self.DesktopEntry.Icon) == 0 {
+		return ExtraFileConfig{}, false
+	}
+	return ExtraFileConfig{
+		Kind: extraFileDesktopIcon,
+		Path: self.DesktopEntry.Icon,
+		Name: self.Name + path.Ext(self.DesktopEntry.Icon),
+	}, true
+}
+
+func (self *AppConfig) GetWriteDesktopEntryOp(clobber ops.ClobberPolicy) ops.Operation {
+	return ops.WriteDesktopEntry{
+		Entry: self.DesktopEntryFile(),
+		DestinationPath: self.DesktopEntryPath(),
+		ManagedDir: self.SystemConfig.ManagedDir(),
+		Clobber: clobber,
+	}
+}
+
+func (self *DesktopEntryConfig) validate(appName string) error {
+	if self == nil { return nil }
+
+	if len(self.Icon) > 0 && !filepath.IsLocal(self.Icon) {
+		return fmt.Errorf(
+			"(app %s) Desktop entry icon \"%s\" must be a relative path within the source dir",
+			appName, self.Icon,
+		)
+	}
+
+	for _, category := range self.Categories {
+		if len(category) == 0 || strings.ContainsAny(category, ";\n") {
+			return fmt.Errorf(
+				"(app %s) Invalid desktop entry category \"%s\"",
+				appName, category,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Only meaningful for apps with a desktop entry config.
+func (self *AppConfig) DesktopEntryFile() ops.DesktopEntry {
+	entry := ops.DesktopEntry{
+		Name: run.CoalesceString(self.DesktopEntry.Name, self.Name),
+		Comment: self.DesktopEntry.Comment,
+		ExecPath: self.BinaryPath(),
+		Categories: self.DesktopEntry.Categories,
+		Terminal: self.DesktopEntry.Terminal,
+	}
+	if iconConfig, hasIcon := self.desktopIconConfig(); hasIcon {
+		entry.IconPath = path.Join(
+			self.extraFileInstallDir(iconConfig.Kind, iconConfig.Name),
+			iconConfig.Name,
+		)
+	}
+	return entry
+}
@@ -0,0 +1,249 @@
+package data
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/ops"
+)
+
+const (
+	ExtraFileMan = "man"
+	ExtraFileBashCompletion = "bash-completion"
+	ExtraFileZshCompletion = "zsh-completion"
+	ExtraFileFishCompletion = "fish-completion"
+	// Any other file, placed in a directory of the app's own under the share dir
+	ExtraFileShare = "share"
+)
+
+var extraFileKinds = []string{
+	ExtraFileMan,
+	ExtraFileBashCompletion,
+	ExtraFileZshCompletion,
+	ExtraFileFishCompletion,
+	ExtraFileShare,
+}
+
+// A file which is installed alongside an app's binary, taken either from the build output or from
+// the output of a command.
+type ExtraFileConfig struct {
+	// Determines where the file is installed: one of man, bash-completion, zsh-completion,
+	// fish-completion, or share
+	Kind string `yaml:"kind"`
+	// Path of the file, relative to the source dir (cannot be combined with command)
+	Path string `yaml:"path,omitempty"`
+	// Shell command whose output is the file, run in the source dir (with the app's build
+	// environment) after the app is built. The app's artifact (or its first build target, for apps
+	// with build-targets) is available to the command as $SELFMAN_ARTIFACT.
+	Command string `yaml:"command,omitempty"`
+	// Name of the installed file. Defaults to the file name of the path, or for completions to the
+	// name each shell expects for the app's completions.
+	Name string `yaml:"name,omitempty"`
+}
+
+// One of an app's extra files, and the places selfman puts it.
+type AppExtraFile struct {
+	Kind string
+	Name string
+	// Where the file is taken from, if it is not captured from a command
+	BuildPath string
+	Command string
+	// Where selfman keeps the file for the app's current version
+	StoredPath string
+	// Where the file is linked (or copied) to
+	InstallPath string
+}
+
+func (self ExtraFileConfig) fileName(appName string) string {
+	if len(self.Name) > 0 { return self.Name }
+	if len(self.Path) > 0 && !isCompletionKind(self.Kind) { return path.Base(self.Path) }
+
+	switch self.Kind {
+	case ExtraFileBashCompletion: return appName
+	case ExtraFileZshCompletion: return "_" + appName
+	case ExtraFileFishCompletion: return appName + ".fish"
+	default: return ""
+	}
+}
+
+func isCompletionKind(kind string) bool {
+	switch kind {
+	case ExtraFileBashCompletion, ExtraFileZshCompletion, ExtraFileFishCompletion: return true
+	default: return false
+	}
+}
+
+// Man pages go in the directory for their section, which is taken from the file extension (e.g.
+// "foo.1" is in section 1). Compressed man pages (e.g. "foo.1.gz") are read by man as they are.
+func manSection(fileName string) (string, bool) {
+	extension := strings.TrimPrefix(path.Ext(strings.TrimSuffix(fileName, ".gz")), ".")
+	if len(extension) == 0 || extension[0] < '1' || extension[0] > '9' { return "", false }
+	return extension, true
+}
+
+func (self *AppConfig) extraFileInstallDir(kind string, fileName string) string {
+	shareDir := *self.SystemConfig.ShareDir
+	switch kind {
+	case ExtraFileMan: {
+		section, _ := manSection(fileName)
+		return path.Join(shareDir, "man", "man" + section)
+	}
+	case ExtraFileBashCompletion: return path.Join(shareDir, "bash-completion", "completions")
+	case ExtraFileZshCompletion: return path.Join(shareDir, "zsh", "site-functions")
+	case ExtraFileFishCompletion: return path.Join(shareDir, "fish", "vendor_completions.d")
+	case extraFileDesktopIcon: return path.Join(shareDir, "icons")
+	default: return path.Join(shareDir, self.Name)
+	}
+}
+
+// The dir holding this app's stored extra files for every version.
+func (self *AppConfig) ExtraFilesPath() string {
+	return path.Join(self.SystemConfig.ExtraFilesPath(), self.Name)
+}
+
+func (self *AppConfig) ExtraFiles() []AppExtraFile {
+	versionDir := path.Join(self.ExtraFilesPath(), escapeVersion(self.Version))
+	configs := self.ExtraFileConfigs
+	if iconConfig, hasIcon := self.desktopIconConfig(); hasIcon {
+		configs = append(slices.Clip(configs), iconConfig)
+	}
+
+	extraFiles := make([]AppExtraFile, 0, len(configs))
+	for _, config := range configs {
+		fileName := config.fileName(self.Name)
+		extraFile := AppExtraFile{
+			Kind: config.Kind,
+			Name: fileName,
+			Command: config.Command,
+			StoredPath: path.Join(versionDir, config.Kind, fileName),
+			InstallPath: path.Join(self.extraFileInstallDir(config.Kind, fileName), fileName),
+		}
+		if len(config.Path) > 0 {
+			extraFile.BuildPath = path.Join(self.SourcePath(), config.Path)
+		}
+		extraFiles = append(extraFiles, extraFile)
+	}
+	return extraFiles
+}
+
+// Stores each of the app's extra files for its current version. These need to run after the app
+// has been built and its targets moved into place.
+func (self *AppConfig) GetStoreExtraFileOps() []ops.Operation {
+	storeOps := make([]ops.Operation, 0, len(self.ExtraFileConfigs))
+	for _, extraFile := range self.ExtraFiles() {
+		if len(extraFile.Command) > 0 {
+			storeOps = append(storeOps, ops.CaptureExtraFile{
+				ScriptShell: *self.SystemConfig.ScriptShell,
+				ScriptCmd: extraFile.Command,
+				WorkingDir: self.SourcePath(),
+				ArtifactPath: self.PrimaryArtifactPath(),
+				DestinationPath: extraFile.StoredPath,
+				Env: self.BuildEnv(),
+				LogDir: self.LogsPath(),
+				AppName: self.Name,
+			})
+			continue
+		}
+		storeOps = append(storeOps, ops.StoreExtraFile{
+			SourcePath: extraFile.BuildPath,
+			DestinationPath: extraFile.StoredPath,
+		})
+	}
+	return storeOps
+}
+
+// Extra files follow the app's link mode, but are never wrapped.
+func (self *AppConfig) GetLinkExtraFileOps(clobber ops.ClobberPolicy) []ops.Operation {
+	linkOps := make([]ops.Operation, 0, len(self.ExtraFileConfigs))
+	for _, extraFile := range self.ExtraFiles() {
+		linkOps = append(linkOps, self.GetLinkExtraFileOp(extraFile, clobber))
+	}
+	return linkOps
+}
+
+func (self *AppConfig) GetLinkExtraFileOp(
+	extraFile AppExtraFile,
+	clobber ops.ClobberPolicy,
+) ops.Operation {
+	if self.EffectiveLinkMode() == LinkModeCopy {
+		return ops.CopyArtifact{
+			SourcePath: extraFile.StoredPath,
+			DestinationPath: extraFile.InstallPath,
+			ManagedDir: self.SystemConfig.ManagedDir(),
+			Clobber: clobber,
+		}
+	}
+
+	return ops.LinkArtifact{
+		SourcePath: extraFile.StoredPath,
+		DestinationPath: extraFile.InstallPath,
+		ManagedDir: self.SystemConfig.ManagedDir(),
+		Clobber: clobber,
+		Relative: self.EffectiveLinkMode() == LinkModeRelative,
+	}
+}
+
+func (self *AppConfig) validateExtraFiles() error {
+	_, hasIcon := self.desktopIconConfig()
+	if (len(self.ExtraFileConfigs) > 0 || hasIcon) && self.Flavor == FlavorBinaryFile {
+		return fmt.Errorf(
+			"(app %s) Apps of flavor %s have no build to take extra files or icons from",
+			self.Name, FlavorBinaryFile,
+		)
+	}
+
+	// the kind and name together determine where a file is installed
+	installedAs := make(map[string]bool, len(self.ExtraFileConfigs))
+	for _, config := range self.ExtraFileConfigs {
+		if !slices.Contains(extraFileKinds, config.Kind) {
+			return fmt.Errorf(
+				"(app %s) Invalid extra file kind \"%s\", must be one of: %s",
+				self.Name, config.Kind, strings.Join(extraFileKinds, ", "),
+			)
+		}
+
+		if (len(config.Path) > 0) == (len(config.Command) > 0) {
+			return fmt.Errorf(
+				"(app %s) Each extra file must have exactly one of path and command",
+				self.Name,
+			)
+		}
+		if len(config.Path) > 0 && !filepath.IsLocal(config.Path) {
+			return fmt.Errorf(
+				"(app %s) Extra file path \"%s\" must be a relative path within the source dir",
+				self.Name, config.Path,
+			)
+		}
+		if len(config.Command) > 0 && !isCompletionKind(config.Kind) && len(config.Name) == 0 {
+			return fmt.Errorf(
+				"(app %s) Extra files of kind %s captured from a command must have a name",
+				self.Name, config.Kind,
+			)
+		}
+
+		fileName := config.fileName(self.Name)
+		if strings.Contains(fileName, "/") || fileName == "." || fileName == ".." {
+			return fmt.Errorf("(app %s) Invalid extra file name \"%s\"", self.Name, fileName)
+		}
+		if _, isManPage := manSection(fileName); config.Kind == ExtraFileMan && !isManPage {
+			return fmt.Errorf(
+				"(app %s) Man page \"%s\" must have its section as its extension (e.g. \"%s.1\")",
+				self.Name, fileName, fileName,
+			)
+		}
+
+		kindAndName := config.Kind + "/" + fileName
+		if installedAs[kindAndName] {
+			return fmt.Errorf(
+				"(app %s) More than one %s extra file is named \"%s\"",
+				self.Name, config.Kind, fileName,
+			)
+		}
+		installedAs[kindAndName] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Commands run with the script shell at points in an app's lifecycle. Each runs with the app's
+// build environment, plus variables describing the app (see HookVars).
+type HooksConfig struct {
+	// Run before the app is built
+	PreBuild string `yaml:"pre-build,omitempty"`
+	// Run once the app has been built and its artifact is in place (and verified, if the app has a
+	// verify command)
+	PostBuild string `yaml:"post-build,omitempty"`
+	// Run after the app's binary has been linked
+	PostLink string `yaml:"post-link,omitempty"`
+	// Run before any of the app's files are removed
+	PreRemove string `yaml:"pre-remove,omitempty"`
+}
+
+const (
+	HookPreBuild = "pre-build"
+	HookPostBuild = "post-build"
+	HookPostLink = "post-link"
+	HookPreRemove = "pre-remove"
+)
+
+// The variables describing the app which are set for its hooks. The commit (for git apps) is read
+// when the hook runs, and is empty for other apps.
+func (self *AppConfig) HookVars() map[string]string {
+	return map[string]string{
+		"SELFMAN_APP_NAME": self.Name,
+		"SELFMAN_APP_VERSION": self.Version,
+		"SELFMAN_SOURCE_PATH": self.SourcePath(),
+		"SELFMAN_ARTIFACT_PATH": self.EntrypointPath(),
+	}
+}
+
+func (self *AppConfig) hookCmd(hook string) string {
+	switch hook {
+	case HookPreBuild: return self.Hooks.PreBuild
+	case HookPostBuild: return self.Hooks.PostBuild
+	case HookPostLink: return self.Hooks.PostLink
+	case HookPreRemove: return self.Hooks.PreRemove
+	}
+
+	run.FailOut(fmt.Sprintf("Unhandled hook name: %s", hook))
+	panic("Unreachable in theory")
+}
+
+// The operation running the given hook. Returns no operations if the app does not have that hook.
+func (self *AppConfig) GetHookOps(hook string) []ops.Operation {
+	cmd := self.hookCmd(hook)
+	if len(cmd) == 0 { return nil }
+
+	hookOp := ops.RunHook{
+		Hook: hook,
+		ScriptShell: *self.SystemConfig.ScriptShell,
+		Cmd: cmd,
+		Env: self.BuildEnv(),
+		AppVars: self.HookVars(),
+		WorkingDir: self.SourcePath(),
+		FallbackDir: *self.SystemConfig.DataDir,
+		LogDir: self.LogsPath(),
+		AppName: self.Name,
+	}
+	if self.Flavor == FlavorGit {
+		hookOp.CommitRepoPath = self.SourcePath()
+	}
+	return []ops.Operation{ hookOp }
+}
+
+func (self *AppConfig) validateHooks() error {
+	hasBuildHook := len(self.Hooks.PreBuild) > 0 || len(self.Hooks.PostBuild) > 0
+	if self.Flavor == FlavorBinaryFile && hasBuildHook {
+		return fmt.Errorf(
+			"(app %s) Apps of flavor %s are never built, so build hooks would never be run",
+			self.Name, FlavorBinaryFile,
+		)
+	}
+	return nil
+}
+
+func (self *HooksConfig) applyPlaceholders(keyVals map[string]string) error {
+	hookCmds := []*string{ &self.PreBuild, &self.PostBuild, &self.PostLink, &self.PreRemove }
+	for _, hookCmd := range hookCmds {
+		var err error
+		*hookCmd, err = replacePlaceholders(*hookCmd, keyVals)
+		if err != nil { return err }
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package data
 import (
 	"os"
 	"path"
+	"strings"
 
 	"github.com/lorentzforces/selfman/internal/git"
+	"github.com/lorentzforces/selfman/internal/ops"
 )
 
 type ManagedFiles interface {
@@ -30,15 +32,58 @@ func (self *AppManagedFiles) AppStatus(appName string) AppStatus {
 		statusReport.AvailableVersions, _ = git.GetAllNamedRevs(foundApp.SourcePath())
 		// TODO: verify that the output of this command is empty if there's an error
 		statusReport.CurrentCommitHash, _ = git.CurrentHeadCommit(foundApp.SourcePath())
+	} else if foundApp.Flavor == FlavorBinaryFile {
+		// the artifact is the only file these apps have
+		statusReport.SourcePresent = fileExists(foundApp.ArtifactPath())
 	} else {
 		statusReport.SourcePresent = dirExistsNotEmpty(foundApp.SourcePath())
 		statusReport.AvailableVersions =
 			getSourceVersions(foundApp.SystemConfig.SourcesPath(), foundApp.Name)
 	}
 
-	statusReport.TargetPresent = fileExists(foundApp.ArtifactPath())
-	statusReport.LinkPresent = linkExists(foundApp.BinaryPath())
-	statusReport.LibLinkPresent = linkExists(foundApp.LibPath())
+	managedDir := foundApp.SystemConfig.ManagedDir()
+	statusReport.TargetPresent = true
+	statusReport.LinkPresent = true
+	for _, artifact := range foundApp.Artifacts() {
+		_, linkPresent := ops.ManagedLinkTarget(artifact.BinaryPath, managedDir)
+		linkStatus := LinkStatus{
+			LinkName: artifact.LinkName,
+			Path: artifact.BinaryPath,
+			Present: linkPresent,
+			Foreign: isForeignFile(artifact.BinaryPath, managedDir),
+		}
+		statusReport.Links = append(statusReport.Links, linkStatus)
+
+		statusReport.TargetPresent = statusReport.TargetPresent && fileExists(artifact.ArtifactPath)
+		statusReport.LinkPresent = statusReport.LinkPresent && linkStatus.Present
+		statusReport.LinkIsForeign = statusReport.LinkIsForeign || linkStatus.Foreign
+	}
+	statusReport.ExtraFilesStored = true
+	for _, extraFile := range foundApp.ExtraFiles() {
+		_, linkPresent := ops.ManagedLinkTarget(extraFile.InstallPath, managedDir)
+		statusReport.ExtraFiles = append(statusReport.ExtraFiles, LinkStatus{
+			LinkName: extraFile.Name,
+			Path: extraFile.InstallPath,
+			Present: linkPresent,
+			Foreign: isForeignFile(extraFile.InstallPath, managedDir),
+		})
+		statusReport.ExtraFilesStored =
+			statusReport.ExtraFilesStored && fileExists(extraFile.StoredPath)
+	}
+	statusReport.ExtraFilesDirPresent = fileExists(foundApp.ExtraFilesPath())
+	statusReport.DesktopEntryPresent = ops.IsSelfmanDesktopEntry(foundApp.DesktopEntryPath())
+	statusReport.DesktopEntryIsForeign =
+		!statusReport.DesktopEntryPresent && fileExists(foundApp.DesktopEntryPath())
+	statusReport.ServiceUnitPresent = ops.IsSelfmanServiceUnit(foundApp.ServiceUnitPath())
+	statusReport.ServiceUnitIsForeign =
+		!statusReport.ServiceUnitPresent && fileExists(foundApp.ServiceUnitPath())
+	statusReport.LibLinkPresent = managedDir.IsLinkInto(foundApp.LibPath())
+	statusReport.LibLinkIsForeign = isForeignFile(foundApp.LibPath(), managedDir)
+	statusReport.BuiltVersions = getArtifactVersions(foundApp)
+	statusReport.VersionLinks = getVersionLinks(foundApp)
+	if statusReport.LinkPresent {
+		statusReport.LinkedVersion = getLinkedArtifactVersion(foundApp)
+	}
 
 	return statusReport
 }
@@ -63,13 +108,13 @@ func fileExists(path string) bool {
 	return true
 }
 
-func linkExists(path string) bool {
-	stat, err := os.Lstat(path)
+// Whether something exists at the given path which was not created by selfman (i.e. is anything
+// other than a symlink into selfman's data dir, a selfman wrapper script, or a recorded copy).
+func isForeignFile(path string, managedDir ops.ManagedDir) bool {
+	_, err := os.Lstat(path)
 	if err != nil { return false }
-	if stat.Mode() & os.ModeSymlink == 0 {
-		return false
-	}
-	return true
+	_, managed := ops.ManagedLinkTarget(path, managedDir)
+	return !managed
 }
 
 func isGitRevPresent(repoPath string, rev string) bool {
@@ -98,3 +143,64 @@ func getSourceVersions(sourcesTopPath string, appName string) []string {
 	}
 	return results
 }
+
+// Apps which keep their binary with their source only ever have a single artifact.
+func getArtifactVersions(app AppConfig) []string {
+	if app.KeepBinWithSource {
+		if fileExists(app.ArtifactPath()) { return []string{ app.Version } }
+		return nil
+	}
+
+	entries, err := os.ReadDir(app.SystemConfig.ArtifactsPath())
+	if err != nil { return nil }
+
+	results := make([]string, 0)
+	for _, entry := range entries {
+		if version, isArtifact := app.ArtifactVersion(entry.Name()); isArtifact {
+			results = append(results, version)
+		}
+	}
+	return results
+}
+
+// Versioned links are found regardless of whether the app currently has LinkVersions set, so that
+// they can still be cleaned up if it has been turned off.
+func getVersionLinks(app AppConfig) []string {
+	entries, err := os.ReadDir(*app.SystemConfig.BinaryDir)
+	if err != nil { return nil }
+
+	results := make([]string, 0)
+	for _, entry := range entries {
+		version, isVersionLink := app.VersionedLinkVersion(entry.Name())
+		if !isVersionLink { continue }
+		linkPath := path.Join(*app.SystemConfig.BinaryDir, entry.Name())
+		if _, managed := ops.ManagedLinkTarget(linkPath, app.SystemConfig.ManagedDir()); managed {
+			results = append(results, version)
+		}
+	}
+	return results
+}
+
+func getLinkedArtifactVersion(app AppConfig) string {
+	// every link points into the same version's artifacts, so the first is enough
+	target, managed :=
+		ops.ManagedLinkTarget(app.Artifacts()[0].BinaryPath, app.SystemConfig.ManagedDir())
+	if !managed { return "" }
+	if len(app.Entrypoint) > 0 {
+		// the link points to the entrypoint inside the version's artifact dir
+		target = strings.TrimSuffix(path.Clean(target), "/" + path.Clean(app.Entrypoint))
+	}
+	if len(app.BuildTargets) > 0 {
+		// the link points to a file inside the version's artifact dir
+		target = path.Dir(path.Clean(target))
+	}
+
+	if app.KeepBinWithSource {
+		if path.Clean(target) == path.Clean(app.ArtifactPath()) { return app.Version }
+		return ""
+	}
+
+	if path.Dir(path.Clean(target)) != path.Clean(app.SystemConfig.ArtifactsPath()) { return "" }
+	version, _ := app.ArtifactVersion(path.Base(target))
+	return version
+}
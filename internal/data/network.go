@@ -0,0 +1,119 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+const (
+	defaultNetworkTimeout = "10m"
+	defaultNetworkBackoff = "2s"
+)
+
+const defaultNetworkRetries = 2
+
+func parseNetworkTimeout(value string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0,
+			fmt.Errorf("Invalid network timeout \"%s\", must be a duration like \"90s\"", value)
+	}
+	return timeout, nil
+}
+
+func validateNetworkRetries(retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("Invalid network retry count %d, must be 0 or more", retries)
+	}
+	return nil
+}
+
+func parseNetworkBackoff(value string) (time.Duration, error) {
+	backoff, err := time.ParseDuration(value)
+	if err != nil || backoff < 0 {
+		return 0,
+			fmt.Errorf("Invalid network backoff \"%s\", must be a duration like \"2s\"", value)
+	}
+	return backoff, nil
+}
+
+func (self *SystemConfig) validateNetworkSettings(origins ConfigOrigins) error {
+	checks := []struct{
+		key string
+		value *string
+		parse func(string) error
+	}{
+		{
+			"network-timeout",
+			self.NetworkTimeout,
+			func(value string) error { _, err := parseNetworkTimeout(value); return err },
+		},
+		{
+			"network-backoff",
+			self.NetworkBackoff,
+			func(value string) error { _, err := parseNetworkBackoff(value); return err },
+		},
+	}
+	for _, check := range checks {
+		if check.value == nil { continue }
+		if err := check.parse(*check.value); err != nil {
+			return fmt.Errorf("%w (from %s)", err, origins.Settings[check.key])
+		}
+	}
+	if self.NetworkRetries != nil {
+		if err := validateNetworkRetries(*self.NetworkRetries); err != nil {
+			return fmt.Errorf("%w (from %s)", err, origins.Settings["network-retries"])
+		}
+	}
+	return nil
+}
+
+// Limits and retries for the app's network operations. App settings take priority over system
+// settings, and both are validated when they are loaded.
+func (self *AppConfig) NetworkPolicy() run.NetworkPolicy {
+	system := self.SystemConfig
+	timeoutValue := *run.Coalesce(system.NetworkTimeout, run.StrPtr(defaultNetworkTimeout))
+	timeoutSource := "network-timeout setting"
+	if len(self.NetworkTimeout) > 0 {
+		timeoutValue = self.NetworkTimeout
+		timeoutSource = fmt.Sprintf("network-timeout setting of app %s", self.Name)
+	}
+	timeout, err := parseNetworkTimeout(timeoutValue)
+	run.AssertNoErr(err)
+
+	systemRetries := run.Coalesce(system.NetworkRetries, run.IntPtr(defaultNetworkRetries))
+	retries := *run.Coalesce(self.NetworkRetries, systemRetries)
+
+	backoffValue := *run.Coalesce(system.NetworkBackoff, run.StrPtr(defaultNetworkBackoff))
+	backoffValue = run.CoalesceString(self.NetworkBackoff, backoffValue)
+	backoff, err := parseNetworkBackoff(backoffValue)
+	run.AssertNoErr(err)
+
+	return run.NetworkPolicy{
+		Timeout: timeout,
+		TimeoutSource: timeoutSource,
+		Retries: retries,
+		Backoff: backoff,
+	}
+}
+
+func (self *AppConfig) validateNetworkSettings() error {
+	if len(self.NetworkTimeout) > 0 {
+		if _, err := parseNetworkTimeout(self.NetworkTimeout); err != nil {
+			return fmt.Errorf("(app %s) %w", self.Name, err)
+		}
+	}
+	if self.NetworkRetries != nil {
+		if err := validateNetworkRetries(*self.NetworkRetries); err != nil {
+			return fmt.Errorf("(app %s) %w", self.Name, err)
+		}
+	}
+	if len(self.NetworkBackoff) > 0 {
+		if _, err := parseNetworkBackoff(self.NetworkBackoff); err != nil {
+			return fmt.Errorf("(app %s) %w", self.Name, err)
+		}
+	}
+	return nil
+}
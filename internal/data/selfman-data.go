@@ -11,17 +11,24 @@ type Selfman struct {
 	SystemConfig *SystemConfig
 	AppConfigs map[string]AppConfig
 	Storage ManagedFiles
+	// Locally-recorded versions which take precedence over the versions in app config files
+	VersionOverrides map[string]string
 }
 
-func Produce() (Selfman, error) {
-	systemConfig, err := loadSystemConfig()
+func Produce(overrides ConfigOverrides) (Selfman, error) {
+	systemConfig, _, err := LoadSystemConfig(overrides)
 	if err != nil { return Selfman{}, err }
 
 	appConfigs, err := loadAppConfigs(&systemConfig)
 	if err != nil { return Selfman{}, err }
 
+	versionOverrides, err := loadVersionOverrides(&systemConfig)
+	if err != nil { return Selfman{}, err }
+	applyVersionOverrides(appConfigs, versionOverrides)
+
 	selfman, err := SelfmanFromValues(&systemConfig, appConfigs, nil)
 	if err != nil { return Selfman{}, err }
+	selfman.VersionOverrides = versionOverrides
 
 	selfman.VerifyAllDirectoriesExist()
 	return selfman, nil
@@ -76,13 +83,59 @@ type AppStatus struct {
 	IsConfigured bool
 	SourcePresent bool
 	TargetPresent bool
+	// All of the app's links are present
 	LinkPresent bool
+	// Something other than a selfman-created link exists at any of the app's link paths
+	LinkIsForeign bool
 	LibLinkPresent bool
+	// Something other than a selfman-created link exists at the library link path
+	LibLinkIsForeign bool
 	DesiredVersion string
 	AvailableVersions []string
+	// Versions which have a built artifact present
+	BuiltVersions []string
+	// The version of the artifact the binary link currently points to, if any
+	LinkedVersion string
+	// Versions which have a side-by-side "name@version" link in the binary dir
+	VersionLinks []string
+	// The status of each of the app's links in the binary dir (one per artifact)
+	Links []LinkStatus
+	// The status of each of the app's installed extra files
+	ExtraFiles []LinkStatus
+	// All of the app's extra files are stored for its current version
+	ExtraFilesStored bool
+	// Extra files are stored for any version of the app
+	ExtraFilesDirPresent bool
+	// A desktop entry written by selfman exists for the app
+	DesktopEntryPresent bool
+	// Something other than a selfman-written desktop entry exists at the desktop entry path
+	DesktopEntryIsForeign bool
+	// A service unit written by selfman exists for the app
+	ServiceUnitPresent bool
+	// Something other than a selfman-written service unit exists at the service unit path
+	ServiceUnitIsForeign bool
 	CurrentCommitHash string
 }
 
+type LinkStatus struct {
+	LinkName string
+	Path string
+	Present bool
+	// Something other than a selfman-created link exists at the link path
+	Foreign bool
+}
+
+// Paths of the app's links which have something selfman did not create at them.
+func (self AppStatus) ForeignLinkPaths() []string {
+	foreignPaths := make([]string, 0)
+	for _, link := range self.Links {
+		if link.Foreign {
+			foreignPaths = append(foreignPaths, link.Path)
+		}
+	}
+	return foreignPaths
+}
+
 func (self AppStatus) FullyPresent() bool {
 	return self.IsConfigured && self.SourcePresent && self.TargetPresent && self.LinkPresent
 }
@@ -96,6 +149,7 @@ func (self AppStatus) ConsistentState() bool {
 const (
 	AppStatusLinkPresent = "installed & linked"
 	AppStatusInconsistent = "partially present - inconsistent state"
+	AppStatusForeignLink = "foreign file at link path"
 	AppStatusIsConfigured = "not present"
 	AppStatusNotConfigured = "unknown app - not configured"
 )
@@ -104,6 +158,7 @@ const (
 func (self AppStatus) Label() string {
 	switch {
 	case self.FullyPresent(): return AppStatusLinkPresent
+	case self.LinkIsForeign || self.LibLinkIsForeign: return AppStatusForeignLink
 	case !self.ConsistentState(): return AppStatusInconsistent
 	case self.IsConfigured: return AppStatusIsConfigured
 	default: return AppStatusNotConfigured
@@ -136,4 +191,5 @@ func (self Selfman) VerifyAllDirectoriesExist() {
 	run.VerifyDirExists(*self.SystemConfig.BinaryDir)
 	run.VerifyDirExists(*self.SystemConfig.LibDir)
 	run.VerifyDirExists(self.SystemConfig.ArtifactsPath())
+	run.VerifyDirExists(self.SystemConfig.MetaPath())
 }
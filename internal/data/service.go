@@ -0,0 +1,114 @@
+package data
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/ops"
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Runs the app as a systemd user service.
+type ServiceConfig struct {
+	// Defaults to the app's name
+	Description string `yaml:"description,omitempty"`
+	// Arguments passed to the app
+	Args []string `yaml:"args,omitempty"`
+	// Environment variables to set (values are used literally)
+	Env map[string]string `yaml:"env,omitempty"`
+	// Directory to run the app from
+	WorkingDir string `yaml:"working-dir,omitempty"`
+	// When systemd restarts the service (defaults to "on-failure")
+	Restart string `yaml:"restart,omitempty"`
+	// The target which starts the service once it is enabled (defaults to "default.target")
+	WantedBy string `yaml:"wanted-by,omitempty"`
+	// Print the systemctl commands needed for changes to the service to take effect
+	PrintCommands bool `yaml:"print-commands,omitempty"`
+}
+
+const (
+	defaultServiceRestart = "on-failure"
+	defaultServiceWantedBy = "default.target"
+)
+
+var serviceRestartValues = []string{
+	"no", "on-success", "on-failure", "on-abnormal", "on-watchdog", "on-abort", "always",
+}
+
+var systemdTargetPattern = regexp.MustCompile(`\A[-A-Za-z0-9_.@]+\.target\z`)
+
+func (self *AppConfig) ServiceUnitName() string {
+	return self.Name + ".service"
+}
+
+func (self *AppConfig) ServiceUnitPath() string {
+	return path.Join(*self.SystemConfig.ServiceDir, self.ServiceUnitName())
+}
+
+// Where systemd links the unit when it is enabled. Only meaningful for apps with a service config.
+func (self *AppConfig) ServiceEnabledLinkPath() string {
+	return path.Join(
+		*self.SystemConfig.ServiceDir,
+		self.ServiceUnit().WantedBy + ".wants",
+		self.ServiceUnitName(),
+	)
+}
+
+// Only meaningful for apps with a service config.
+func (self *AppConfig) ServiceUnit() ops.ServiceUnit {
+	return ops.ServiceUnit{
+		Description: run.CoalesceString(self.Service.Description, self.Name),
+		ExecPath: self.BinaryPath(),
+		Args: self.Service.Args,
+		Env: self.Service.Env,
+		WorkingDir: self.Service.WorkingDir,
+		Restart: run.CoalesceString(self.Service.Restart, defaultServiceRestart),
+		WantedBy: run.CoalesceString(self.Service.WantedBy, defaultServiceWantedBy),
+	}
+}
+
+func (self *AppConfig) GetWriteServiceUnitOp(clobber ops.ClobberPolicy) ops.Operation {
+	return ops.WriteServiceUnit{
+		Unit: self.ServiceUnit(),
+		DestinationPath: self.ServiceUnitPath(),
+		ManagedDir: self.SystemConfig.ManagedDir(),
+		Clobber: clobber,
+	}
+}
+
+func (self *ServiceConfig) validate(appName string) error {
+	if self == nil { return nil }
+
+	if strings.ContainsAny(self.Description, "\n\r") {
+		return fmt.Errorf("(app %s) Service description must be a single line", appName)
+	}
+
+	if len(self.Restart) > 0 && !slices.Contains(serviceRestartValues, self.Restart) {
+		return fmt.Errorf(
+			"(app %s) Invalid service restart setting \"%s\", must be one of: %s",
+			appName, self.Restart, strings.Join(serviceRestartValues, ", "),
+		)
+	}
+
+	if len(self.WantedBy) > 0 && nil == systemdTargetPattern.FindStringIndex(self.WantedBy) {
+		return fmt.Errorf(
+			"(app %s) Service wanted-by \"%s\" must be the name of a systemd target",
+			appName, self.WantedBy,
+		)
+	}
+
+	for name := range self.Env {
+		if nil == envVarNamePattern.FindStringIndex(name) {
+			return fmt.Errorf(
+				"(app %s) Service env var name \"%s\" must be only letters, digits, and " +
+					"underscores, and cannot start with a digit",
+				appName, name,
+			)
+		}
+	}
+
+	return nil
+}
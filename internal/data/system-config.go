@@ -5,11 +5,197 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/lorentzforces/selfman/internal/ops"
 	"github.com/lorentzforces/selfman/internal/run"
 )
 
-const ConfigurationEnvVar = "SELFMAN_CONFIG"
+const (
+	ConfigurationEnvVar = "SELFMAN_CONFIG"
+	ProfileEnvVar = "SELFMAN_PROFILE"
+)
+
+// Describes a single system setting, and how it can be provided outside of the config file.
+type systemSetting struct {
+	// The name of the setting, as used in the config file
+	key string
+	// The environment variable which overrides the setting
+	envVar string
+	// The command-line flag which overrides the setting, if there is one
+	flag string
+	// Exactly one of these is set, depending on the type of the setting
+	field func(*SystemConfig) **string
+	intField func(*SystemConfig) **int
+}
+
+func (self systemSetting) isSet(config *SystemConfig) bool {
+	if self.intField != nil { return *self.intField(config) != nil }
+	return *self.field(config) != nil
+}
+
+// The setting's value as it would be written in the config file, or empty if it is not set.
+func (self systemSetting) displayValue(config *SystemConfig) string {
+	if !self.isSet(config) { return "" }
+	if self.intField != nil { return strconv.Itoa(**self.intField(config)) }
+	return **self.field(config)
+}
+
+// Sets the setting from a value given as text (e.g. in an environment variable).
+func (self systemSetting) setFromString(config *SystemConfig, value string) error {
+	if self.intField == nil {
+		*self.field(config) = &value
+		return nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Invalid %s \"%s\", must be a whole number", self.key, value)
+	}
+	*self.intField(config) = &number
+	return nil
+}
+
+func (self systemSetting) copyValue(dest *SystemConfig, src *SystemConfig) {
+	if self.intField != nil {
+		*self.intField(dest) = *self.intField(src)
+		return
+	}
+	*self.field(dest) = *self.field(src)
+}
+
+var systemSettings = []systemSetting{
+	{
+		key: "app-config-dir",
+		envVar: "SELFMAN_APP_CONFIG_DIR",
+		flag: "app-config-dir",
+		field: func(config *SystemConfig) **string { return &config.AppConfigDir },
+	},
+	{
+		key: "data-dir",
+		envVar: "SELFMAN_DATA_DIR",
+		flag: "data-dir",
+		field: func(config *SystemConfig) **string { return &config.DataDir },
+	},
+	{
+		key: "binary-dir",
+		envVar: "SELFMAN_BIN_DIR",
+		flag: "bin-dir",
+		field: func(config *SystemConfig) **string { return &config.BinaryDir },
+	},
+	{
+		key: "lib-dir",
+		envVar: "SELFMAN_LIB_DIR",
+		flag: "lib-dir",
+		field: func(config *SystemConfig) **string { return &config.LibDir },
+	},
+	{
+		key: "share-dir",
+		envVar: "SELFMAN_SHARE_DIR",
+		field: func(config *SystemConfig) **string { return &config.ShareDir },
+	},
+	{
+		key: "service-dir",
+		envVar: "SELFMAN_SERVICE_DIR",
+		field: func(config *SystemConfig) **string { return &config.ServiceDir },
+	},
+	{
+		key: "script-shell",
+		envVar: "SELFMAN_SCRIPT_SHELL",
+		field: func(config *SystemConfig) **string { return &config.ScriptShell },
+	},
+	{
+		key: "link-mode",
+		envVar: "SELFMAN_LINK_MODE",
+		field: func(config *SystemConfig) **string { return &config.LinkMode },
+	},
+	{
+		key: "network-timeout",
+		envVar: "SELFMAN_NETWORK_TIMEOUT",
+		field: func(config *SystemConfig) **string { return &config.NetworkTimeout },
+	},
+	{
+		key: "network-retries",
+		envVar: "SELFMAN_NETWORK_RETRIES",
+		intField: func(config *SystemConfig) **int { return &config.NetworkRetries },
+	},
+	{
+		key: "network-backoff",
+		envVar: "SELFMAN_NETWORK_BACKOFF",
+		field: func(config *SystemConfig) **string { return &config.NetworkBackoff },
+	},
+}
+
+const (
+	LinkModeAbsolute = "absolute"
+	LinkModeRelative = "relative"
+	// Copies of artifacts keep working even if selfman's data dir is not available at the same path
+	LinkModeCopy = "copy"
+)
+
+var linkModes = []string{ LinkModeAbsolute, LinkModeRelative, LinkModeCopy }
+
+func isValidLinkMode(mode string) bool {
+	return slices.Contains(linkModes, mode)
+}
+
+// Values provided directly by the user (i.e. via command-line flags). These take priority over
+// environment variables, which in turn take priority over the config file.
+type ConfigOverrides struct {
+	// Path to the config file, overriding any other means of locating it
+	ConfigPath string
+	// Name of the profile to use. If nil, the profile is taken from the environment. If set to an
+	// empty string, no profile is used.
+	Profile *string
+	System SystemConfig
+}
+
+const (
+	OriginDefault = "default"
+	OriginConfigFile = "config file"
+	OriginDefaultLocation = "default location"
+)
+
+// Records where each effective system setting came from.
+type ConfigOrigins struct {
+	// Empty if no config file was found
+	ConfigFilePath string
+	ConfigFileOrigin string
+	// Empty if no profile is in use
+	ProfileOrigin string
+	// setting key -> origin description
+	Settings map[string]string
+}
+
+// A single effective system setting, for display purposes.
+type EffectiveSetting struct {
+	Key string
+	Value string
+	Origin string
+}
+
+// Returns each system setting in a stable order, along with where its value came from.
+func (self ConfigOrigins) EffectiveSettings(config *SystemConfig) []EffectiveSetting {
+	results := make([]EffectiveSetting, 0, len(systemSettings))
+	for _, setting := range systemSettings {
+		results = append(results, EffectiveSetting{
+			Key: setting.key,
+			Value: setting.displayValue(config),
+			Origin: self.Settings[setting.key],
+		})
+	}
+	return results
+}
+
+func envVarOrigin(envVar string) string {
+	return fmt.Sprintf("env var %s", envVar)
+}
+
+func flagOrigin(flag string) string {
+	return fmt.Sprintf("flag --%s", flag)
+}
 
 func DefaultTestConfig() *SystemConfig {
 	return &SystemConfig{
@@ -17,57 +203,209 @@ func DefaultTestConfig() *SystemConfig {
 		DataDir: run.StrPtr("/tmp/selfman-test/data"),
 		BinaryDir: run.StrPtr("/tmp/selfman-test/bin"),
 		LibDir: run.StrPtr("/tmp/selfman-test/lib"),
+		ShareDir: run.StrPtr("/tmp/selfman-test/share"),
+		ServiceDir: run.StrPtr("/tmp/selfman-test/systemd"),
 		ScriptShell: run.StrPtr("/bin/sh"),
+		LinkMode: run.StrPtr(LinkModeAbsolute),
+		NetworkTimeout: run.StrPtr(defaultNetworkTimeout),
+		NetworkRetries: run.IntPtr(defaultNetworkRetries),
+		NetworkBackoff: run.StrPtr(defaultNetworkBackoff),
 	}
 }
 
-func loadSystemConfig() (SystemConfig, error) {
-	path, err := resolveConfigPath(ConfigurationEnvVar)
+// Resolves the effective system config from (in increasing order of priority) defaults, the
+// config file, environment variables, and the provided overrides.
+func LoadSystemConfig(overrides ConfigOverrides) (SystemConfig, ConfigOrigins, error) {
+	origins := ConfigOrigins{ Settings: make(map[string]string, len(systemSettings)) }
+
+	path, pathOrigin, err := resolveConfigPath(overrides.ConfigPath, ConfigurationEnvVar)
 	if err != nil {
-		return SystemConfig{}, fmt.Errorf("Could not resolve config file: %w", err)
+		return SystemConfig{}, origins, fmt.Errorf("Could not resolve config file: %w", err)
+	}
+	origins.ConfigFilePath = path
+	origins.ConfigFileOrigin = pathOrigin
+
+	profile, profileOrigin := resolveProfile(overrides.Profile)
+	origins.ProfileOrigin = profileOrigin
+
+	finalConfig := SystemConfig{}
+	finalConfig = layerConfig(finalConfig, defaultConfig(), OriginDefault, origins)
+
+	configData := SystemConfig{}
+	if len(path) > 0 {
+		configData, err = readConfigFile(path)
+		if err != nil { return SystemConfig{}, origins, err }
+		finalConfig = layerConfig(finalConfig, configData, OriginConfigFile, origins)
+	}
+
+	if len(profile) > 0 {
+		finalConfig.useProfileDirs(profile, origins)
+		profileConfig, present := configData.Profiles[profile]
+		if !present {
+			return SystemConfig{}, origins, fmt.Errorf(
+				"Profile \"%s\" (from %s) is not defined in the config file",
+				profile, profileOrigin,
+			)
+		}
+		finalConfig = layerConfig(
+			finalConfig, profileConfig, fmt.Sprintf("profile \"%s\"", profile), origins,
+		)
+	}
+
+	for _, setting := range systemSettings {
+		envValue := os.Getenv(setting.envVar)
+		if len(envValue) == 0 { continue }
+
+		envConfig := SystemConfig{}
+		err := setting.setFromString(&envConfig, envValue)
+		if err != nil {
+			return SystemConfig{}, origins,
+				fmt.Errorf("%w (from %s)", err, envVarOrigin(setting.envVar))
+		}
+		finalConfig = layerConfig(finalConfig, envConfig, envVarOrigin(setting.envVar), origins)
+	}
+
+	for _, setting := range systemSettings {
+		if len(setting.flag) == 0 { continue }
+
+		overrideConfig := SystemConfig{}
+		setting.copyValue(&overrideConfig, &overrides.System)
+		finalConfig = layerConfig(finalConfig, overrideConfig, flagOrigin(setting.flag), origins)
+	}
+
+	if !isValidLinkMode(*finalConfig.LinkMode) {
+		return SystemConfig{}, origins, fmt.Errorf(
+			"Invalid link mode \"%s\" (from %s), must be one of: %s",
+			*finalConfig.LinkMode, origins.Settings["link-mode"], strings.Join(linkModes, ", "),
+		)
 	}
 
-	defaultConfig := defaultConfig()
+	if err := finalConfig.validateNetworkSettings(origins); err != nil {
+		return SystemConfig{}, origins, err
+	}
+
+	finalConfig.Profile = profile
+	finalConfig.expandPaths()
+	return finalConfig, origins, nil
+}
+
+// Returns the names of all profiles defined in the config file (which is resolved the same way as
+// in LoadSystemConfig).
+func ProfileNames(overrides ConfigOverrides) ([]string, error) {
+	path, _, err := resolveConfigPath(overrides.ConfigPath, ConfigurationEnvVar)
+	if err != nil { return nil, fmt.Errorf("Could not resolve config file: %w", err) }
+	if len(path) == 0 { return nil, nil }
 
-	if len(path) == 0 { return defaultConfig, nil }
+	configData, err := readConfigFile(path)
+	if err != nil { return nil, err }
 
+	names := make([]string, 0, len(configData.Profiles))
+	for name := range configData.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func readConfigFile(path string) (SystemConfig, error) {
 	configFile, err := os.Open(path)
 	run.AssertNoErrReason(err, "Config file was resolved but later reading failed")
+	defer configFile.Close()
+
 	configData := SystemConfig{}
 	err = run.GetStrictDecoder(configFile).Decode(&configData)
 	if err != nil {
 		return SystemConfig{}, fmt.Errorf("Error parsing config file: %w", err)
 	}
 
-	finalConfig := coalesceConfigs(defaultConfig, configData)
+	for name, profileConfig := range configData.Profiles {
+		if len(name) == 0 || !validLabelPattern.MatchString(name) {
+			return SystemConfig{}, fmt.Errorf(
+				"Profile name \"%s\" must be only upper & lower case letters, hyphens, and " +
+					"underscores",
+				name,
+			)
+		}
+		if len(profileConfig.Profiles) > 0 {
+			return SystemConfig{}, fmt.Errorf(
+				"Profile \"%s\" cannot itself define profiles", name)
+		}
+	}
+
+	return configData, nil
+}
 
-	finalConfig.expandPaths()
-	return finalConfig, nil
+// Returns the selected profile name (empty if no profile is selected), and a description of where
+// the selection came from.
+func resolveProfile(explicitProfile *string) (string, string) {
+	if explicitProfile != nil {
+		if len(*explicitProfile) == 0 { return "", "" }
+		return *explicitProfile, flagOrigin("profile")
+	}
+
+	envProfile := os.Getenv(ProfileEnvVar)
+	if len(envProfile) > 0 {
+		return envProfile, envVarOrigin(ProfileEnvVar)
+	}
+	return "", ""
 }
 
-// Returns the first-resolved configuration location. Resolves in this order of priority:
+// Layers the given config on top of the base config, recording the given origin for every
+// setting the layer provides.
+func layerConfig(
+	base SystemConfig,
+	layer SystemConfig,
+	origin string,
+	origins ConfigOrigins,
+) SystemConfig {
+	for _, setting := range systemSettings {
+		if setting.isSet(&layer) {
+			origins.Settings[setting.key] = origin
+		}
+	}
+	return coalesceConfigs(base, layer)
+}
+
+// Returns the first-resolved configuration location, as well as a description of where that
+// location came from. Resolves in this order of priority:
+//   - the explicitly provided path (i.e. from a command-line flag)
 //   - $SELFMAN_CONFIG
-//   - $XDG_CONFIG_HOME/selfman/selfman.config
-//   - ~/.config/selfman/selfman.config
-// If $SELFMAN_CONFIG is set but no readable file exists at that path, this function will return
-// an error.
+//   - $XDG_CONFIG_HOME/selfman/config.yaml
+//   - ~/.config/selfman/config.yaml
+// If a path is explicitly provided or $SELFMAN_CONFIG is set but no readable file exists at that
+// path, this function will return an error.
 //
 // If no readable file is resolved by the above process, an empty string is returned with no error.
-func resolveConfigPath(configEnvName string) (string, error) {
+func resolveConfigPath(explicitPath string, configEnvName string) (string, string, error) {
+	if len(explicitPath) > 0 {
+		found, err := checkFileAtPath(explicitPath)
+		if !found {
+			return "", "", fmt.Errorf(
+				"Configuration path was specified via flag but was not found: \"%s\"",
+				explicitPath,
+			)
+		}
+		if err != nil {
+			return "", "", fmt.Errorf(
+				"Configuration path was specified via flag but was not readable: %w", err)
+		}
+		return explicitPath, flagOrigin("config"), nil
+	}
+
 	configEnvPath := os.Getenv(configEnvName)
 	if len(configEnvPath) > 0 {
 		found, err := checkFileAtPath(configEnvPath)
 		if !found {
-			return "", fmt.Errorf(
+			return "", "", fmt.Errorf(
 				"Configuration path was specified in env var %s but was not found", configEnvName)
 		}
 		if err != nil {
-			return "", fmt.Errorf(
+			return "", "", fmt.Errorf(
 				"Configuration path was specified in env var %s but was not readable: %w",
 				configEnvName, err,
 			)
 		}
-		return configEnvPath, nil
+		return configEnvPath, envVarOrigin(configEnvName), nil
 	}
 
 	configXdgPath := path.Join(resolveXdgConfigDir(), "selfman", "config.yaml")
@@ -75,12 +413,12 @@ func resolveConfigPath(configEnvName string) (string, error) {
 
 	switch {
 	case found && err != nil:
-		return "", fmt.Errorf(
+		return "", "", fmt.Errorf(
 			"Configuration found at path \"%s\" with error: %w",
 			configXdgPath, err,
 		)
-	case found: return configXdgPath, nil
-	default: return "", nil
+	case found: return configXdgPath, OriginDefaultLocation, nil
+	default: return "", "", nil
 	}
 }
 
@@ -110,7 +448,13 @@ func coalesceConfigs(a, b SystemConfig) SystemConfig {
 	result.DataDir = run.Coalesce(b.DataDir, a.DataDir)
 	result.BinaryDir = run.Coalesce(b.BinaryDir, a.BinaryDir)
 	result.LibDir = run.Coalesce(b.LibDir, a.LibDir)
+	result.ShareDir = run.Coalesce(b.ShareDir, a.ShareDir)
+	result.ServiceDir = run.Coalesce(b.ServiceDir, a.ServiceDir)
 	result.ScriptShell = run.Coalesce(b.ScriptShell, a.ScriptShell)
+	result.LinkMode = run.Coalesce(b.LinkMode, a.LinkMode)
+	result.NetworkTimeout = run.Coalesce(b.NetworkTimeout, a.NetworkTimeout)
+	result.NetworkRetries = run.Coalesce(b.NetworkRetries, a.NetworkRetries)
+	result.NetworkBackoff = run.Coalesce(b.NetworkBackoff, a.NetworkBackoff)
 	return result
 }
 
@@ -123,16 +467,46 @@ type SystemConfig struct {
 	BinaryDir *string `yaml:"binary-dir,omitempty"`
 	// The directory in which to link library directories
 	LibDir *string `yaml:"lib-dir,omitempty"`
+	// The directory under which apps' extra files (man pages, shell completions, etc.) are placed
+	ShareDir *string `yaml:"share-dir,omitempty"`
+	// The directory in which systemd user units for apps' services are placed
+	ServiceDir *string `yaml:"service-dir,omitempty"`
 	// The shell to be used to invoke build scripts. Defaults to "/bin/sh", will be invoked with
 	// the "-c" option.
 	ScriptShell *string `yaml:"script-shell,omitempty"`
+	// How artifacts are placed in the binary dir: as absolute symlinks, relative symlinks, or
+	// copies. Defaults to "absolute", and can be overridden per-app.
+	LinkMode *string `yaml:"link-mode,omitempty"`
+	// How long each attempt at a git clone, git fetch, or web download may take (e.g. "90s",
+	// "10m"). Defaults to 10 minutes, and can be overridden per-app.
+	NetworkTimeout *string `yaml:"network-timeout,omitempty"`
+	// How many times a failed git clone, git fetch, or web download is retried. Defaults to 2, and
+	// can be overridden per-app.
+	NetworkRetries *int `yaml:"network-retries,omitempty"`
+	// How long to wait before the first retry, doubling for each retry after that (plus some
+	// random jitter). Defaults to 2 seconds, and can be overridden per-app.
+	NetworkBackoff *string `yaml:"network-backoff,omitempty"`
+	// Named sets of settings which can be selected in place of the top-level settings. Any
+	// setting not provided by a profile falls back to the top-level setting, except for the data
+	// and app config dirs, which default to a subdir of the top-level ones. Profiles may share the
+	// binary and lib dirs, but links placed by one are foreign files to the others.
+	Profiles map[string]SystemConfig `yaml:"profiles,omitempty"`
+	// The name of the profile in use (empty if none is)
+	Profile string `yaml:"-"`
 }
 
 func (self *SystemConfig) expandPaths() {
-	*self.AppConfigDir = os.ExpandEnv(*self.AppConfigDir)
-	*self.DataDir = os.ExpandEnv(*self.DataDir)
-	*self.BinaryDir = os.ExpandEnv(*self.BinaryDir)
-	*self.LibDir = os.ExpandEnv(*self.LibDir)
+	self.AppConfigDir = run.StrPtr(os.ExpandEnv(*self.AppConfigDir))
+	self.DataDir = run.StrPtr(os.ExpandEnv(*self.DataDir))
+	self.BinaryDir = run.StrPtr(os.ExpandEnv(*self.BinaryDir))
+	self.LibDir = run.StrPtr(os.ExpandEnv(*self.LibDir))
+	self.ShareDir = run.StrPtr(os.ExpandEnv(*self.ShareDir))
+	self.ServiceDir = run.StrPtr(os.ExpandEnv(*self.ServiceDir))
+}
+
+// The path of the config file selfman itself creates for an app (e.g. when adopting a binary).
+func (self *SystemConfig) AppConfigFilePath(appName string) string {
+	return path.Join(*self.AppConfigDir, appName + ".config.yaml")
 }
 
 func (self *SystemConfig) SourcesPath() string {
@@ -143,17 +517,82 @@ func (self *SystemConfig) ArtifactsPath() string {
 	return path.Join(*self.DataDir, "artifacts")
 }
 
+// Builds of apps with a verify command are kept here until they pass verification, so that a
+// broken build never replaces a working artifact.
+func (self *SystemConfig) StagingPath() string {
+	return path.Join(*self.DataDir, "staging")
+}
+
+// Files which selfman did not create, but replaced at the user's request, are backed up here.
+func (self *SystemConfig) BackupsPath() string {
+	return path.Join(*self.DataDir, "backups")
+}
+
+// Extra files (man pages, shell completions, etc.) for each version of an app are kept here, and
+// linked into the share dir.
+func (self *SystemConfig) ExtraFilesPath() string {
+	return path.Join(*self.DataDir, "extra-files")
+}
+
+// Output from each app's builds and git operations is logged here.
+func (self *SystemConfig) LogsPath() string {
+	return path.Join(*self.DataDir, "logs")
+}
+
 func (self *SystemConfig) MetaPath() string {
 	return path.Join(*self.DataDir, "meta")
 }
 
+// Artifacts placed with link-mode "copy" are recorded here, so that they can be recognized later.
+func (self *SystemConfig) CopyRecordsPath() string {
+	return path.Join(self.MetaPath(), "installed-copies.yaml")
+}
+
+// Selfman's data dir, as seen by the operations which place files outside of it. Profiles' data
+// dirs are within it by default, but their files are not this installation's to manage.
+func (self *SystemConfig) ManagedDir() ops.ManagedDir {
+	return ops.ManagedDir{
+		Path: *self.DataDir,
+		CopyRecordsPath: self.CopyRecordsPath(),
+		ExcludedPaths: []string{ self.profilesDataPath() },
+	}
+}
+
+func (self *SystemConfig) profilesDataPath() string {
+	return path.Join(*self.DataDir, "profiles")
+}
+
+// Selfman holds this lock while executing operations, so that two instances cannot modify the
+// same data at the same time.
+func (self *SystemConfig) LockPath() string {
+	return path.Join(self.MetaPath(), "selfman.lock")
+}
+
+// Profiles get their own app config and data directories (within the top-level ones) unless they
+// set their own, so that their apps, and selfman's state and lock for those apps, are kept
+// separate from the top-level ones.
+func (self *SystemConfig) useProfileDirs(profile string, origins ConfigOrigins) {
+	self.AppConfigDir = run.StrPtr(path.Join(*self.AppConfigDir, "profiles", profile))
+	self.DataDir = run.StrPtr(path.Join(self.profilesDataPath(), profile))
+	for _, key := range []string{ "app-config-dir", "data-dir" } {
+		origins.Settings[key] =
+			fmt.Sprintf("%s, within profiles/%s", origins.Settings[key], profile)
+	}
+}
+
 func defaultConfig() SystemConfig {
 	return SystemConfig{
 		AppConfigDir: run.StrPtr(path.Join(resolveXdgConfigDir(), "selfman", "apps")),
 		DataDir: run.StrPtr(path.Join(resolveXdgDataDir(), "selfman")),
 		BinaryDir: run.StrPtr(resolveXdgBinDir()),
 		LibDir: run.StrPtr(resolveUserLibDir()),
+		ShareDir: run.StrPtr(resolveXdgDataDir()),
+		ServiceDir: run.StrPtr(path.Join(resolveXdgConfigDir(), "systemd", "user")),
 		ScriptShell: run.StrPtr("/bin/sh"),
+		LinkMode: run.StrPtr(LinkModeAbsolute),
+		NetworkTimeout: run.StrPtr(defaultNetworkTimeout),
+		NetworkRetries: run.IntPtr(defaultNetworkRetries),
+		NetworkBackoff: run.StrPtr(defaultNetworkBackoff),
 	}
 }
 
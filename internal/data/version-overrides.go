@@ -0,0 +1,60 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"gopkg.in/yaml.v3"
+)
+
+// Versions recorded locally (i.e. not in an app's config file) take precedence over configured
+// versions. They are stored as a simple map of app name -> version.
+func (self *SystemConfig) VersionOverridesPath() string {
+	return path.Join(self.MetaPath(), "version-overrides.yaml")
+}
+
+func loadVersionOverrides(systemConfig *SystemConfig) (map[string]string, error) {
+	overrides := make(map[string]string)
+
+	contents, err := os.ReadFile(systemConfig.VersionOverridesPath())
+	if errors.Is(err, os.ErrNotExist) { return overrides, nil }
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Could not read version overrides file \"%s\": %w",
+			systemConfig.VersionOverridesPath(), err,
+		)
+	}
+
+	err = yaml.Unmarshal(contents, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Could not parse version overrides file \"%s\": %w",
+			systemConfig.VersionOverridesPath(), err,
+		)
+	}
+	if overrides == nil {
+		overrides = make(map[string]string)
+	}
+	return overrides, nil
+}
+
+// Overrides for apps which are not configured are left in place (but unused), in case the app is
+// only temporarily missing its config.
+func applyVersionOverrides(apps []AppConfig, overrides map[string]string) {
+	for i := range apps {
+		version, present := overrides[apps[i].Name]
+		if !present { continue }
+		apps[i].ConfigFileVersion = apps[i].Version
+		apps[i].Version = version
+		apps[i].VersionIsOverridden = true
+	}
+}
+
+func VersionOverridesFileContents(overrides map[string]string) string {
+	contents, err := yaml.Marshal(overrides)
+	run.AssertNoErrReason(err, "version overrides could not be serialized")
+	return string(contents)
+}
@@ -1,8 +1,13 @@
 package git
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/lorentzforces/selfman/internal/run"
 )
@@ -12,28 +17,66 @@ func ExecExists() bool {
 	return err == nil
 }
 
-func Clone(url string, destPath string) error {
-	_, err := run.NewCmd("git", run.WithArgs("clone", url, destPath), run.WithTimeout(30)).Exec()
-	return err
-}
+// Checkouts are local, so they are not limited like network operations are. Their limit is only
+// there in case git hangs.
+const localTimeout = 5 * time.Minute
 
-func Fetch(repoPath string) error {
-	_, err := run.NewCmd(
-		"git",
-		run.WithArgs("fetch", "--tags"),
-		run.WithTimeout(30),
-		run.WithWorkingDir(repoPath),
-	).Exec()
+// Git runs in the background (in its own process group) so it cannot prompt for credentials.
+// Without this it would wait for input it can never get until it timed out.
+const noPromptEnvVar = "GIT_TERMINAL_PROMPT"
 
-	return err
+func Clone(
+	ctx context.Context,
+	url string,
+	destPath string,
+	network run.NetworkPolicy,
+	output run.CmdOutput,
+) error {
+	return network.Retry(ctx, func() error {
+		_, err := run.NewCmd(
+			"git",
+			run.WithArgs("clone", url, destPath),
+			run.WithContext(ctx),
+			run.WithEnv(noPromptEnvVar, "0"),
+			run.WithTimeout(network.Timeout, network.TimeoutSource),
+			run.WithOutput(output),
+		).Exec()
+		if err != nil {
+			// a failed clone can leave a partial repo behind, which the next attempt would trip on
+			os.RemoveAll(destPath)
+		}
+		return err
+	})
+}
+
+func Fetch(
+	ctx context.Context,
+	repoPath string,
+	network run.NetworkPolicy,
+	output run.CmdOutput,
+) error {
+	return network.Retry(ctx, func() error {
+		_, err := run.NewCmd(
+			"git",
+			run.WithArgs("fetch", "--tags"),
+			run.WithContext(ctx),
+			run.WithEnv(noPromptEnvVar, "0"),
+			run.WithTimeout(network.Timeout, network.TimeoutSource),
+			run.WithWorkingDir(repoPath),
+			run.WithOutput(output),
+		).Exec()
+		return err
+	})
 }
 
-func Checkout(repoPath string, ref string) error {
+func Checkout(ctx context.Context, repoPath string, ref string, output run.CmdOutput) error {
 	_, err := run.NewCmd(
 		"git",
 		run.WithArgs("checkout", ref),
-		run.WithTimeout(5),
+		run.WithContext(ctx),
+		run.WithTimeout(localTimeout, "selfman's fixed limit for local git commands"),
 		run.WithWorkingDir(repoPath),
+		run.WithOutput(output),
 	).Exec()
 	return err
 }
@@ -97,3 +140,36 @@ func CurrentHeadCommit(repoPath string) (string, error) {
 	).Exec()
 	return strings.TrimSpace(output), err
 }
+
+// The oldest git version selfman is known to work with. ("--end-of-options" was added in 2.24)
+const (
+	MinimumMajorVersion = 2
+	MinimumMinorVersion = 24
+)
+
+// Returns the version of the git executable on PATH, e.g. "2.43.0"
+func Version() (string, error) {
+	output, err := run.NewCmd("git", run.WithArgs("version")).Exec()
+	if err != nil { return "", err }
+	// output is of the form "git version 2.43.0", possibly with platform-specific suffixes
+	fields := strings.Fields(output)
+	if len(fields) < 3 {
+		return "", fmt.Errorf("Unexpected output from git version: %s", output)
+	}
+	return fields[2], nil
+}
+
+// Whether the given git version string meets selfman's minimum required version.
+func VersionIsSupported(version string) (bool, error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false, fmt.Errorf("Could not parse git version: %s", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil { return false, fmt.Errorf("Could not parse git version: %s", version) }
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil { return false, fmt.Errorf("Could not parse git version: %s", version) }
+
+	if major != MinimumMajorVersion { return major > MinimumMajorVersion, nil }
+	return minor >= MinimumMinorVersion, nil
+}
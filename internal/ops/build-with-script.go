@@ -1,7 +1,12 @@
 package ops
 
 import (
+	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/lorentzforces/selfman/internal/run"
 )
@@ -10,13 +15,26 @@ type BuildWithScript struct {
 	SourcePath string
 	ScriptShell string
 	ScriptCmd string
+	Env run.Env
+	// If set, the build's output is logged to the build log in this dir
+	LogDir string
+	// Streamed output from the build is prefixed with this
+	AppName string
+	// If set, the build is stopped after running this long
+	Timeout time.Duration
 }
 
-func (self BuildWithScript) Execute() (string, error) {
+func (self BuildWithScript) Execute(ctx context.Context) (string, error) {
+	ctx, cancel := withBuildTimeout(ctx, self.Timeout, self.AppName)
+	defer cancel()
+
 	_, err := run.NewCmd(
 		self.ScriptShell,
 		run.WithArgs("-c", self.ScriptCmd),
+		run.WithContext(ctx),
 		run.WithWorkingDir(self.SourcePath),
+		run.WithEnvironment(self.Env),
+		run.WithOutput(appCmdOutput(self.AppName, self.LogDir, buildLogKind)),
 	).Exec()
 	if err != nil {
 		return "", fmt.Errorf("Error while running build script: %w", err)
@@ -25,18 +43,55 @@ func (self BuildWithScript) Execute() (string, error) {
 	return "Executed build script", nil
 }
 
+// Limits a build to its timeout, if it has one.
+func withBuildTimeout(
+	ctx context.Context,
+	timeout time.Duration,
+	appName string,
+) (context.Context, context.CancelFunc) {
+	if timeout <= 0 { return ctx, func() {} }
+	source := fmt.Sprintf("build-timeout setting of app %s", appName)
+	return context.WithTimeoutCause(ctx, timeout, run.TimeoutError(timeout, source))
+}
+
+// Context lines describing how a build's environment differs from selfman's own, if it does.
+func describeEnv(env run.Env) []string {
+	lines := make([]string, 0)
+	if env.Clean {
+		lines = append(lines, "clean environment")
+	}
+	for _, name := range slices.Sorted(maps.Keys(env.Vars)) {
+		lines = append(lines, fmt.Sprintf("env: %s=%s", name, env.Vars[name]))
+	}
+	if len(env.PathPrepend) > 0 {
+		lines = append(
+			lines,
+			fmt.Sprintf("prepended to PATH: %s", strings.Join(env.PathPrepend, ":")),
+		)
+	}
+	return lines
+}
+
 func (self BuildWithScript) Describe() OpDescription {
 	topLine := "Build app with script"
 	sourcePath := fmt.Sprintf("source path: %s", self.SourcePath)
 	scriptShell := fmt.Sprintf("shell: %s -c", self.ScriptShell)
 	scriptCmd := fmt.Sprintf("script command: %s", self.ScriptCmd)
 
-	return OpDescription{
-		TopLine: topLine,
-		ContextLines: []string{
+	contextLines := append(
+		[]string{
 			sourcePath,
 			scriptShell,
 			scriptCmd,
 		},
+		describeEnv(self.Env)...,
+	)
+	if self.Timeout > 0 {
+		contextLines = append(contextLines, fmt.Sprintf("timeout: %s", self.Timeout))
+	}
+
+	return OpDescription{
+		TopLine: topLine,
+		ContextLines: contextLines,
 	}
 }
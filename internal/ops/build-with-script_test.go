@@ -0,0 +1,161 @@
+package ops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailedBuildReportsOutputTailAndLogFile(t *testing.T) {
+	logDir := t.TempDir()
+	build := BuildWithScript{
+		SourcePath: t.TempDir(),
+		ScriptShell: "/bin/sh",
+		ScriptCmd: "seq 1 30 >&2; echo 'compile error' >&2; exit 3",
+		LogDir: logDir,
+	}
+
+	_, buildErr := build.Execute(t.Context())
+	assert.Error(t, buildErr)
+	run.BailIfFailed(t)
+
+	logFiles, err := os.ReadDir(logDir)
+	assert.NoError(t, err)
+	assert.Len(t, logFiles, 1)
+	run.BailIfFailed(t)
+	logPath := path.Join(logDir, logFiles[0].Name())
+	assert.Regexp(t, `^\d{4}-\d\d-\d\dT\d\d-\d\d-\d\d\.\d{3}-build\.log$`, logFiles[0].Name())
+
+	var cmdErr *run.CmdError
+	assert.True(t, errors.As(buildErr, &cmdErr))
+	run.BailIfFailed(t)
+	assert.Equal(t, logPath, cmdErr.LogPath())
+	assert.Contains(t, buildErr.Error(), "Full output is in log file: " + logPath)
+	assert.Contains(t, buildErr.Error(), "OUTPUT:\n12\n13\n", "Only the last lines are included")
+	assert.NotContains(t, buildErr.Error(), "\n11\n")
+	assert.Contains(t, buildErr.Error(), "30\ncompile error")
+
+	contents, err := os.ReadFile(logPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "\n1\n2\n3\n", "The log has the full output")
+	assert.Contains(t, string(contents), "compile error\n=== finished: exit status 3\n")
+}
+
+func TestSuccessfulBuildIsLogged(t *testing.T) {
+	logDir := t.TempDir()
+	build := BuildWithScript{
+		SourcePath: t.TempDir(),
+		ScriptShell: "/bin/sh",
+		ScriptCmd: "echo built",
+		LogDir: logDir,
+	}
+
+	_, err := build.Execute(t.Context())
+	assert.NoError(t, err)
+	logFiles, err := os.ReadDir(logDir)
+	assert.NoError(t, err)
+	assert.Len(t, logFiles, 1)
+	run.BailIfFailed(t)
+
+	contents, err := os.ReadFile(path.Join(logDir, logFiles[0].Name()))
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "=== $ /bin/sh -c 'echo built'\n")
+	assert.Contains(t, string(contents), "built\n=== finished: success\n")
+}
+
+func TestBuildOutputIsStreamedWithAppPrefix(t *testing.T) {
+	var streamed bytes.Buffer
+	run.StreamCmdOutput(&streamed)
+	defer run.StreamCmdOutput(nil)
+
+	build := BuildWithScript{
+		SourcePath: t.TempDir(),
+		ScriptShell: "/bin/sh",
+		ScriptCmd: "echo first; echo warning >&2; printf 'no newline'",
+		AppName: "loud",
+	}
+	_, err := build.Execute(t.Context())
+	assert.NoError(t, err)
+
+	assert.Contains(t, streamed.String(), "[loud] first\n")
+	assert.Contains(t, streamed.String(), "[loud] warning\n")
+	assert.Contains(t, streamed.String(), "[loud] no newline\n", "Unfinished lines are flushed")
+}
+
+func TestBuildIsStoppedAfterTimeout(t *testing.T) {
+	build := BuildWithScript{
+		SourcePath: t.TempDir(),
+		ScriptShell: "/bin/sh",
+		ScriptCmd: "sleep 30",
+		AppName: "slow",
+		Timeout: 100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := build.Execute(t.Context())
+	assert.Less(t, time.Since(start), 5 * time.Second)
+	assert.ErrorContains(
+		t,
+		err,
+		"Timed out after 100ms (limit set by build-timeout setting of app slow)",
+	)
+}
+
+func TestBuildIsStoppedWhenInterrupted(t *testing.T) {
+	build := BuildWithScript{
+		SourcePath: t.TempDir(),
+		ScriptShell: "/bin/sh",
+		ScriptCmd: "sleep 30",
+	}
+
+	ctx, cancel := context.WithCancelCause(t.Context())
+	time.AfterFunc(100 * time.Millisecond, func() { cancel(run.ErrInterrupted) })
+
+	start := time.Now()
+	_, err := build.Execute(ctx)
+	assert.Less(t, time.Since(start), 5 * time.Second)
+	assert.ErrorIs(t, err, run.ErrInterrupted)
+}
+
+func TestInterruptedBuildStopsProcessesIgnoringIt(t *testing.T) {
+	pidPath := path.Join(t.TempDir(), "pid")
+	build := BuildWithScript{
+		SourcePath: t.TempDir(),
+		ScriptShell: "/bin/sh",
+		// a process the build starts, which outlives the build itself when asked to stop
+		ScriptCmd: "sh -c 'trap \"\" TERM; echo $$ > \"$0\"; exec sleep 30' " +
+			run.ShellQuote(pidPath) + " & wait",
+	}
+
+	ctx, cancel := context.WithCancelCause(t.Context())
+	time.AfterFunc(200 * time.Millisecond, func() { cancel(run.ErrInterrupted) })
+
+	_, err := build.Execute(ctx)
+	assert.ErrorIs(t, err, run.ErrInterrupted)
+	pidContents, err := os.ReadFile(pidPath)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidContents)))
+	assert.NoError(t, err)
+
+	// a killed process may linger briefly as a zombie until it is reaped
+	assert.Eventually(
+		t,
+		func() bool {
+			stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+			return err != nil || strings.Contains(string(stat), ") Z ")
+		},
+		time.Second,
+		50 * time.Millisecond,
+	)
+}
@@ -0,0 +1,211 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Builds a Go module's main package (in the root of the source dir) into a single binary.
+type BuildWithGo struct {
+	SourcePath string
+	OutputPath string
+	Env run.Env
+	// If set, the build's output is logged to the build log in this dir
+	LogDir string
+	// Streamed output from the build is prefixed with this
+	AppName string
+	// If set, the build is stopped after running this long
+	Timeout time.Duration
+}
+
+func (self BuildWithGo) Execute(ctx context.Context) (string, error) {
+	ctx, cancel := withBuildTimeout(ctx, self.Timeout, self.AppName)
+	defer cancel()
+
+	err := runToolchainCmd(
+		ctx,
+		self.SourcePath,
+		self.Env,
+		appCmdOutput(self.AppName, self.LogDir, buildLogKind),
+		"go", "build", "-o", self.OutputPath, ".",
+	)
+	if err != nil { return "", err }
+	return "Built app with go", nil
+}
+
+func (self BuildWithGo) Describe() OpDescription {
+	return describeToolchainBuild(
+		"go",
+		self.SourcePath,
+		self.Env,
+		self.Timeout,
+		[]string{ "go build -o " + self.OutputPath + " ." },
+	)
+}
+
+// Builds a Rust crate in release mode, which places binaries in target/release.
+type BuildWithCargo struct {
+	SourcePath string
+	Env run.Env
+	// If set, the build's output is logged to the build log in this dir
+	LogDir string
+	// Streamed output from the build is prefixed with this
+	AppName string
+	// If set, the build is stopped after running this long
+	Timeout time.Duration
+}
+
+func (self BuildWithCargo) Execute(ctx context.Context) (string, error) {
+	ctx, cancel := withBuildTimeout(ctx, self.Timeout, self.AppName)
+	defer cancel()
+
+	err := runToolchainCmd(
+		ctx,
+		self.SourcePath,
+		self.Env,
+		appCmdOutput(self.AppName, self.LogDir, buildLogKind),
+		"cargo", "build", "--release",
+	)
+	if err != nil { return "", err }
+	return "Built app with cargo", nil
+}
+
+func (self BuildWithCargo) Describe() OpDescription {
+	return describeToolchainBuild(
+		"cargo",
+		self.SourcePath,
+		self.Env,
+		self.Timeout,
+		[]string{ "cargo build --release" },
+	)
+}
+
+// Runs the default target of the source dir's makefile.
+type BuildWithMake struct {
+	SourcePath string
+	Env run.Env
+	// If set, the build's output is logged to the build log in this dir
+	LogDir string
+	// Streamed output from the build is prefixed with this
+	AppName string
+	// If set, the build is stopped after running this long
+	Timeout time.Duration
+}
+
+func (self BuildWithMake) Execute(ctx context.Context) (string, error) {
+	ctx, cancel := withBuildTimeout(ctx, self.Timeout, self.AppName)
+	defer cancel()
+
+	err := runToolchainCmd(
+		ctx,
+		self.SourcePath,
+		self.Env,
+		appCmdOutput(self.AppName, self.LogDir, buildLogKind),
+		"make",
+	)
+	if err != nil { return "", err }
+	return "Built app with make", nil
+}
+
+func (self BuildWithMake) Describe() OpDescription {
+	return describeToolchainBuild(
+		"make",
+		self.SourcePath,
+		self.Env,
+		self.Timeout,
+		[]string{ "make" },
+	)
+}
+
+// Configures a CMake project for a release build in the "build" dir of the source dir, then builds
+// it there.
+type BuildWithCmake struct {
+	SourcePath string
+	Env run.Env
+	// If set, the build's output is logged to the build log in this dir
+	LogDir string
+	// Streamed output from the build is prefixed with this
+	AppName string
+	// If set, the build is stopped after running this long
+	Timeout time.Duration
+}
+
+var cmakeCommands = [][]string{
+	{ "cmake", "-S", ".", "-B", "build", "-DCMAKE_BUILD_TYPE=Release" },
+	{ "cmake", "--build", "build" },
+}
+
+func (self BuildWithCmake) Execute(ctx context.Context) (string, error) {
+	ctx, cancel := withBuildTimeout(ctx, self.Timeout, self.AppName)
+	defer cancel()
+
+	// both steps are logged to the same file
+	output := appCmdOutput(self.AppName, self.LogDir, buildLogKind)
+	for _, command := range cmakeCommands {
+		err := runToolchainCmd(ctx, self.SourcePath, self.Env, output, command[0], command[1:]...)
+		if err != nil { return "", err }
+	}
+	return "Built app with cmake", nil
+}
+
+func (self BuildWithCmake) Describe() OpDescription {
+	commandLines := make([]string, 0, len(cmakeCommands))
+	for _, command := range cmakeCommands {
+		commandLines = append(commandLines, strings.Join(command, " "))
+	}
+	return describeToolchainBuild("cmake", self.SourcePath, self.Env, self.Timeout, commandLines)
+}
+
+// The toolchain's executable is looked for up front, so that a missing toolchain is reported
+// clearly rather than as a failed command.
+func runToolchainCmd(
+	ctx context.Context,
+	sourcePath string,
+	env run.Env,
+	output run.CmdOutput,
+	executable string,
+	args ...string,
+) error {
+	if _, found := env.LookPath(executable); !found {
+		return fmt.Errorf("Building requires \"%s\", but it was not found on PATH", executable)
+	}
+
+	_, err := run.NewCmd(
+		executable,
+		run.WithArgs(args...),
+		run.WithContext(ctx),
+		run.WithWorkingDir(sourcePath),
+		run.WithEnvironment(env),
+		run.WithOutput(output),
+	).Exec()
+	if err != nil {
+		return fmt.Errorf("Error while building with %s: %w", executable, err)
+	}
+	return nil
+}
+
+func describeToolchainBuild(
+	toolchain string,
+	sourcePath string,
+	env run.Env,
+	timeout time.Duration,
+	commands []string,
+) OpDescription {
+	contextLines := []string{ fmt.Sprintf("source path: %s", sourcePath) }
+	for _, command := range commands {
+		contextLines = append(contextLines, fmt.Sprintf("command: %s", command))
+	}
+	contextLines = append(contextLines, describeEnv(env)...)
+	if timeout > 0 {
+		contextLines = append(contextLines, fmt.Sprintf("timeout: %s", timeout))
+	}
+
+	return OpDescription{
+		TopLine: fmt.Sprintf("Build app with %s", toolchain),
+		ContextLines: contextLines,
+	}
+}
@@ -0,0 +1,67 @@
+package ops
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolchainBuildFailsClearlyWithoutToolchain(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := BuildWithCargo{ SourcePath: t.TempDir() }.Execute(t.Context())
+	assert.ErrorContains(t, err, "Building requires \"cargo\", but it was not found on PATH")
+}
+
+func TestToolchainBuildUsesAppEnvironment(t *testing.T) {
+	t.Setenv("SELFMAN_TEST_INHERITED", "inherited")
+	toolDir := t.TempDir()
+	sourceDir := t.TempDir()
+	fakeMake := "#!/bin/sh\n" +
+		"echo \"$GREETING:$SELFMAN_TEST_INHERITED:$PATH\" > built.txt\n"
+	run.AssertNoErr(os.WriteFile(path.Join(toolDir, "make"), []byte(fakeMake), 0o755))
+
+	_, err := BuildWithMake{
+		SourcePath: sourceDir,
+		Env: run.Env{
+			Vars: map[string]string{ "GREETING": "hello" },
+			PathPrepend: []string{ toolDir },
+			Clean: true,
+		},
+	}.Execute(t.Context())
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	output, err := os.ReadFile(path.Join(sourceDir, "built.txt"))
+	assert.NoError(t, err)
+	assert.Equal(
+		t,
+		"hello::" + toolDir + ":/usr/local/bin:/usr/bin:/bin\n",
+		string(output),
+		"Inherited vars are dropped in a clean environment, and prepended dirs come first",
+	)
+}
+
+func TestPrependingToEmptyPathAddsNoEmptyEntry(t *testing.T) {
+	toolDir := t.TempDir()
+	sourceDir := t.TempDir()
+	fakeMake := "#!/bin/sh\necho \"$PATH\" > built.txt\n"
+	run.AssertNoErr(os.WriteFile(path.Join(toolDir, "make"), []byte(fakeMake), 0o755))
+
+	_, err := BuildWithMake{
+		SourcePath: sourceDir,
+		Env: run.Env{
+			Vars: map[string]string{ "PATH": "" },
+			PathPrepend: []string{ toolDir },
+		},
+	}.Execute(t.Context())
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	output, err := os.ReadFile(path.Join(sourceDir, "built.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, toolDir + "\n", string(output), "The working dir must not end up on PATH")
+}
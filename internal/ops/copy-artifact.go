@@ -0,0 +1,74 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Places a copy of an artifact (instead of a link to it), for when links into selfman's data dir
+// may not resolve.
+type CopyArtifact struct {
+	SourcePath string
+	DestinationPath string
+	ManagedDir ManagedDir
+	Clobber ClobberPolicy
+}
+
+func (self CopyArtifact) Execute(ctx context.Context) (string, error) {
+	backupMsg, err := clearLinkDestination(self.DestinationPath, self.ManagedDir, self.Clobber)
+	if err != nil {
+		return "", fmt.Errorf("Copying artifact failed while replacing existing file: %w", err)
+	}
+
+	err = run.VerifyDirExists(path.Dir(self.DestinationPath))
+	if err != nil { return "", fmt.Errorf("Error creating dir for artifact copy: %w", err) }
+
+	err = copyFilePreservingMode(self.SourcePath, self.DestinationPath)
+	if err != nil { return "", fmt.Errorf("Copying artifact failed: %w", err) }
+
+	err = recordCopy(self.ManagedDir, self.DestinationPath, self.SourcePath)
+	if err != nil { return "", fmt.Errorf("Recording artifact copy failed: %w", err) }
+
+	return appendDetail("Copied artifact", backupMsg), nil
+}
+
+func (self CopyArtifact) Describe() OpDescription {
+	return OpDescription{
+		TopLine: "Copy app artifact binary",
+		ContextLines: []string{
+			fmt.Sprintf("from: %s", self.SourcePath),
+			fmt.Sprintf("to: %s", self.DestinationPath),
+			fmt.Sprintf("existing files: %s", self.Clobber.describe()),
+		},
+	}
+}
+
+// The copy is written next to its destination and then renamed into place, so that a partial
+// copy is never left at the destination.
+func copyFilePreservingMode(srcPath string, destPath string) error {
+	srcStat, err := os.Stat(srcPath)
+	if err != nil { return err }
+
+	srcFile, err := os.Open(srcPath)
+	if err != nil { return err }
+	defer srcFile.Close()
+
+	tempFile, err := os.CreateTemp(path.Dir(destPath), "." + path.Base(destPath) + ".*")
+	if err != nil { return err }
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+
+	_, err = io.Copy(tempFile, srcFile)
+	if err != nil { return err }
+	err = tempFile.Chmod(srcStat.Mode().Perm())
+	if err != nil { return err }
+	err = tempFile.Close()
+	if err != nil { return err }
+
+	return os.Rename(tempFile.Name(), destPath)
+}
@@ -0,0 +1,71 @@
+package ops
+
+import (
+	"errors"
+	"os"
+	"path"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"gopkg.in/yaml.v3"
+)
+
+// Unlike links and wrapper scripts, a copied artifact carries no trace of where it came from. So
+// that copies can be recognized later, selfman records each one it places (along with a hash of
+// its contents, so that a file which has since been replaced is not mistaken for selfman's).
+type copyRecord struct {
+	Artifact string `yaml:"artifact"`
+	Sha256 string `yaml:"sha256"`
+}
+
+// Returns copied file path -> record. Missing or unreadable records are treated as empty.
+func readCopyRecords(managedDir ManagedDir) map[string]copyRecord {
+	records := make(map[string]copyRecord)
+	contents, err := os.ReadFile(managedDir.CopyRecordsPath)
+	if err != nil { return records }
+	_ = yaml.Unmarshal(contents, &records)
+	if records == nil {
+		records = make(map[string]copyRecord)
+	}
+	return records
+}
+
+func recordCopy(managedDir ManagedDir, copyPath string, artifactPath string) error {
+	hash, err := run.FileSha256(copyPath)
+	if err != nil { return err }
+
+	records := readCopyRecords(managedDir)
+	// drop records for copies which no longer exist, so the file doesn't grow forever
+	for recordedPath := range records {
+		if _, err := os.Lstat(recordedPath); errors.Is(err, os.ErrNotExist) {
+			delete(records, recordedPath)
+		}
+	}
+	records[copyPath] = copyRecord{ Artifact: artifactPath, Sha256: hash }
+
+	contents, err := yaml.Marshal(records)
+	run.AssertNoErrReason(err, "copy records could not be serialized")
+	err = run.VerifyDirExists(path.Dir(managedDir.CopyRecordsPath))
+	if err != nil { return err }
+	return os.WriteFile(managedDir.CopyRecordsPath, contents, 0o644)
+}
+
+// Whether the file at the given path is an unmodified copy placed by selfman. Copies keep working
+// even if the artifact they were copied from is gone.
+func IsRecordedCopy(filePath string, managedDir ManagedDir) bool {
+	_, recorded := readCopyArtifact(filePath, managedDir)
+	return recorded
+}
+
+// If the file at the given path is an unmodified copy placed by selfman, returns the path of the
+// artifact it was copied from.
+func readCopyArtifact(filePath string, managedDir ManagedDir) (string, bool) {
+	stat, err := os.Lstat(filePath)
+	if err != nil || !stat.Mode().IsRegular() { return "", false }
+
+	record, recorded := readCopyRecords(managedDir)[filePath]
+	if !recorded { return "", false }
+
+	hash, err := run.FileSha256(filePath)
+	if err != nil || hash != record.Sha256 { return "", false }
+	return record.Artifact, true
+}
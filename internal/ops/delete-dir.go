@@ -1,6 +1,7 @@
 package ops
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -11,7 +12,7 @@ type DeleteDir struct {
 	Path string
 }
 
-func (self DeleteDir) Execute() (string, error) {
+func (self DeleteDir) Execute(ctx context.Context) (string, error) {
 	stat, err := os.Stat(self.Path)
 	if err != nil && errors.Is(err, os.ErrNotExist) {
 		return "", fmt.Errorf("Dir to delete does not exist: %s", self.Path)
@@ -1,6 +1,7 @@
 package ops
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -11,7 +12,7 @@ type DeleteFile struct {
 	Path string
 }
 
-func (self DeleteFile) Execute() (string, error) {
+func (self DeleteFile) Execute(ctx context.Context) (string, error) {
 	stat, err := os.Lstat(self.Path)
 	if err != nil && errors.Is(err, os.ErrNotExist) {
 		return "Deleted file (already gone)", nil
@@ -1,6 +1,7 @@
 package ops
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -14,7 +15,7 @@ type DeleteFilesWithPrefix struct {
 	FilePrefix string
 }
 
-func (self DeleteFilesWithPrefix) Execute() (string, error) {
+func (self DeleteFilesWithPrefix) Execute(ctx context.Context) (string, error) {
 	stat, err := os.Lstat(self.DirPath)
 	if err != nil && errors.Is(err, os.ErrNotExist) {
 		return "", fmt.Errorf("Provided directory path does not exist: %w", err)
@@ -39,7 +40,8 @@ func (self DeleteFilesWithPrefix) Execute() (string, error) {
 			continue
 		}
 		matchedFiles++
-		err = os.Remove(path.Join(self.DirPath, file.Name()))
+		// matches may be dirs (e.g. the artifacts of an app with multiple build targets)
+		err = os.RemoveAll(path.Join(self.DirPath, file.Name()))
 		if err != nil {
 			deleteErrors = append(deleteErrors, err)
 		}
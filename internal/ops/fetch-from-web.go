@@ -1,6 +1,7 @@
 package ops
 
 import (
+	"context"
 	"fmt"
 	"path"
 	"strings"
@@ -12,12 +13,13 @@ type FetchFromWeb struct {
 	SourceUrl string
 	Version string
 	DestinationDir string
+	Network run.NetworkPolicy
 }
 
-func (self FetchFromWeb) Execute() (string, error) {
+func (self FetchFromWeb) Execute(ctx context.Context) (string, error) {
 	fullUrl := strings.ReplaceAll(self.SourceUrl, "%VERSION%", self.Version)
 
-	tmpFile, err := run.GetFileFromUrl(fullUrl)
+	tmpFile, err := run.GetFileFromUrl(ctx, fullUrl, self.Network)
 	if err != nil { return "", fmt.Errorf("Fetch from web failed: %w", err) }
 
 	// we add a hidden dummy file so the directory isn't empty if we move the only file out of it
@@ -46,6 +48,15 @@ func (self FetchFromWeb) Describe() OpDescription {
 			sourceUrl,
 			versionString,
 			destination,
+			describeNetwork(self.Network),
 		},
 	}
 }
+
+// Shared by every operation which goes over the network.
+func describeNetwork(network run.NetworkPolicy) string {
+	return fmt.Sprintf(
+		"network: %s timeout per attempt, %d retries (backoff from %s)",
+		network.Timeout, network.Retries, network.Backoff,
+	)
+}
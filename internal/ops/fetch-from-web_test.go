@@ -0,0 +1,93 @@
+package ops
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func testNetworkPolicy() run.NetworkPolicy {
+	return run.NetworkPolicy{
+		Timeout: 5 * time.Second,
+		TimeoutSource: "test setting",
+		Retries: 2,
+		Backoff: time.Millisecond,
+	}
+}
+
+func TestFetchFromWebRetriesServerErrors(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("app contents"))
+	}))
+	defer server.Close()
+
+	destDir := t.TempDir()
+	fetch := FetchFromWeb{
+		SourceUrl: server.URL + "/app-%VERSION%",
+		Version: "1.0",
+		DestinationDir: destDir,
+		Network: testNetworkPolicy(),
+	}
+	_, err := fetch.Execute(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, requests)
+
+	contents, err := os.ReadFile(path.Join(destDir, "app-1.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, "app contents", string(contents))
+
+	requests = 0
+	fetch.Network.Retries = 1
+	_, err = fetch.Execute(t.Context())
+	assert.ErrorContains(t, err, "Failed after 2 attempts")
+	assert.ErrorContains(t, err, "503")
+}
+
+func TestFetchFromWebDoesNotRetryMissingFiles(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := FetchFromWeb{
+		SourceUrl: server.URL + "/app",
+		DestinationDir: t.TempDir(),
+		Network: testNetworkPolicy(),
+	}.Execute(t.Context())
+	assert.ErrorContains(t, err, "404")
+	assert.Equal(t, 1, requests)
+}
+
+func TestFetchFromWebTimeoutNamesTheLimit(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	network := testNetworkPolicy()
+	network.Timeout = 20 * time.Millisecond
+	network.Retries = 0
+	_, err := FetchFromWeb{
+		SourceUrl: server.URL + "/app",
+		DestinationDir: t.TempDir(),
+		Network: network,
+	}.Execute(t.Context())
+	assert.ErrorContains(t, err, "Timed out after 20ms (limit set by test setting)")
+}
@@ -0,0 +1,56 @@
+package ops
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Whether the file at the given path starts with the given marker line, which selfman writes at
+// the top of files it generates so it can recognize them later.
+func hasMarkerLine(filePath string, markerLine string) bool {
+	stat, err := os.Lstat(filePath)
+	if err != nil || !stat.Mode().IsRegular() { return false }
+
+	file, err := os.Open(filePath)
+	if err != nil { return false }
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	return scanner.Scan() && scanner.Text() == markerLine
+}
+
+// Writes a generated file (whose contents start with markerLine). A previous version of the file
+// is always replaced, but anything else at the path is subject to the clobber policy.
+//
+// Returns a message describing any backup which was made.
+func writeGeneratedFile(
+	destPath string,
+	contents string,
+	markerLine string,
+	managedDir ManagedDir,
+	clobber ClobberPolicy,
+) (string, error) {
+	backupMsg := ""
+	if hasMarkerLine(destPath, markerLine) {
+		err := os.Remove(destPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("Could not remove previous version of file: %w", err)
+		}
+	} else {
+		var err error
+		backupMsg, err = clearLinkDestination(destPath, managedDir, clobber)
+		if err != nil { return "", fmt.Errorf("Could not replace existing file: %w", err) }
+	}
+
+	err := run.VerifyDirExists(path.Dir(destPath))
+	if err != nil { return "", fmt.Errorf("Error creating parent dir for file: %w", err) }
+	err = os.WriteFile(destPath, []byte(contents), 0o644)
+	if err != nil { return "", err }
+
+	return backupMsg, nil
+}
@@ -1,6 +1,7 @@
 package ops
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/lorentzforces/selfman/internal/git"
@@ -9,10 +10,19 @@ import (
 type GitCheckoutRef struct {
 	RepoPath string
 	RefName string
+	// If set, git's output is logged to a new file in this dir
+	LogDir string
+	// Streamed output from git is prefixed with this
+	AppName string
 }
 
-func (self GitCheckoutRef) Execute() (string, error) {
-	err := git.Checkout(self.RepoPath, self.RefName)
+func (self GitCheckoutRef) Execute(ctx context.Context) (string, error) {
+	err := git.Checkout(
+		ctx,
+		self.RepoPath,
+		self.RefName,
+		appCmdOutput(self.AppName, self.LogDir, "git-checkout"),
+	)
 	// TODO: consider figuring out some more graceful way of handling the case where the requested
 	// ref just doesn't exist
 	if err != nil { return "", fmt.Errorf("Git checkout failed: %w", err) }
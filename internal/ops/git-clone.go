@@ -1,18 +1,31 @@
 package ops
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/lorentzforces/selfman/internal/git"
+	"github.com/lorentzforces/selfman/internal/run"
 )
 
 type GitClone struct {
 	RepoUrl string
 	DestinationPath string
+	Network run.NetworkPolicy
+	// If set, git's output is logged to a new file in this dir
+	LogDir string
+	// Streamed output from git is prefixed with this
+	AppName string
 }
 
-func (self GitClone) Execute() (string, error) {
-	err := git.Clone(self.RepoUrl, self.DestinationPath)
+func (self GitClone) Execute(ctx context.Context) (string, error) {
+	err := git.Clone(
+		ctx,
+		self.RepoUrl,
+		self.DestinationPath,
+		self.Network,
+		appCmdOutput(self.AppName, self.LogDir, "git-clone"),
+	)
 	if err != nil {
 		return "", fmt.Errorf("Git clone failed: %w", err)
 	}
@@ -29,6 +42,7 @@ func (self GitClone) Describe() OpDescription {
 		ContextLines: []string{
 			urlLine,
 			destLine,
+			describeNetwork(self.Network),
 		},
 	}
 }
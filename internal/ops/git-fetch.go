@@ -1,17 +1,29 @@
 package ops
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/lorentzforces/selfman/internal/git"
+	"github.com/lorentzforces/selfman/internal/run"
 )
 
 type GitFetch struct {
 	RepoPath string
+	Network run.NetworkPolicy
+	// If set, git's output is logged to a new file in this dir
+	LogDir string
+	// Streamed output from git is prefixed with this
+	AppName string
 }
 
-func (self GitFetch) Execute() (string, error) {
-	err := git.Fetch(self.RepoPath)
+func (self GitFetch) Execute(ctx context.Context) (string, error) {
+	err := git.Fetch(
+		ctx,
+		self.RepoPath,
+		self.Network,
+		appCmdOutput(self.AppName, self.LogDir, "git-fetch"),
+	)
 	if err != nil { return "", fmt.Errorf("Git fetch failed: %w", err) }
 	return "Executed git fetch", nil
 }
@@ -24,6 +36,7 @@ func (self GitFetch) Describe() OpDescription {
 		TopLine: topLine,
 		ContextLines: []string{
 			repoPath,
+			describeNetwork(self.Network),
 		},
 	}
 }
@@ -0,0 +1,53 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Returned when selfman is interrupted, naming the operation which was cut short (or the last
+// one which finished, if the interruption came between operations).
+type InterruptedError struct {
+	// The top line of the operation's description
+	Operation string
+	// Whether the operation finished before the interruption was noticed
+	Finished bool
+	err error
+}
+
+func (self InterruptedError) Error() string {
+	if self.Finished {
+		return fmt.Sprintf(
+			"Interrupted after operation \"%s\" finished, no further operations were executed",
+			self.Operation,
+		)
+	}
+	return fmt.Sprintf(
+		"Interrupted during operation \"%s\", no further operations were executed",
+		self.Operation,
+	)
+}
+
+func (self InterruptedError) Unwrap() error {
+	return self.err
+}
+
+// If the context has been cancelled, attributes the operation's error to the interruption. Errors
+// already attributed to one of a meta operation's inner operations are left alone, so that the
+// innermost operation is the one named.
+func AttributeInterruption(ctx context.Context, op Operation, err error) error {
+	if err == nil || ctx.Err() == nil { return err }
+	var interrupted InterruptedError
+	if errors.As(err, &interrupted) { return err }
+	return InterruptedError{ Operation: op.Describe().TopLine, err: err }
+}
+
+// The error for an interruption noticed between operations, after the given operation finished.
+func InterruptedAfter(ctx context.Context, op Operation) error {
+	return InterruptedError{
+		Operation: op.Describe().TopLine,
+		Finished: true,
+		err: context.Cause(ctx),
+	}
+}
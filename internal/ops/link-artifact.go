@@ -1,37 +1,45 @@
 package ops
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"os"
 )
 
 type LinkArtifact struct {
 	SourcePath string
 	DestinationPath string
+	ManagedDir ManagedDir
+	Clobber ClobberPolicy
+	// Link with a path relative to the link's directory, instead of an absolute path
+	Relative bool
 }
 
-func (self LinkArtifact) Execute() (string, error) {
-	err := os.Remove(self.DestinationPath)
-	if err != nil && ! errors.Is(err, os.ErrNotExist) {
-		return "", fmt.Errorf("Linking artifact failed while deleting existing link: %w", err)
+func (self LinkArtifact) Execute(ctx context.Context) (string, error) {
+	backupMsg, err := clearLinkDestination(self.DestinationPath, self.ManagedDir, self.Clobber)
+	if err != nil {
+		return "", fmt.Errorf("Linking artifact failed while replacing existing file: %w", err)
 	}
 
-	err = os.Symlink(self.SourcePath, self.DestinationPath)
+	err = createSymlink(self.SourcePath, self.DestinationPath, self.Relative)
 	if err != nil { return "", fmt.Errorf("Linking artifact failed: %w", err) }
-	return "Linked artifact", nil
+	return appendDetail("Linked artifact", backupMsg), nil
 }
 
 func (self LinkArtifact) Describe() OpDescription {
 	topLine := "Link app artifact binary"
 	fromLine := fmt.Sprintf("from: %s", self.SourcePath)
+	if self.Relative {
+		fromLine += " (relative link)"
+	}
 	toLine := fmt.Sprintf("to: %s", self.DestinationPath)
+	clobberLine := fmt.Sprintf("existing files: %s", self.Clobber.describe())
 
 	return OpDescription{
 		TopLine: topLine,
 		ContextLines: []string{
 			fromLine,
 			toLine,
+			clobberLine,
 		},
 	}
 }
@@ -0,0 +1,132 @@
+package ops
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Where selfman keeps the files it manages. Files it places elsewhere (links, wrapper scripts, and
+// copies) are recognized by what they point to in here, or by the records it keeps here. Operations
+// placing files always replace such files, since selfman created them.
+type ManagedDir struct {
+	// Selfman's data dir
+	Path string
+	// Where selfman records the artifact copies it has placed
+	CopyRecordsPath string
+	// Dirs within Path which belong to other selfman installations (i.e. profiles), so files
+	// pointing into them are not selfman's to manage
+	ExcludedPaths []string
+}
+
+// Whether the given path is one of selfman's files, rather than one belonging to another
+// installation.
+func (self ManagedDir) Contains(filePath string) bool {
+	if !run.IsPathWithin(filePath, self.Path) { return false }
+	for _, excludedPath := range self.ExcludedPaths {
+		if run.IsPathWithin(filePath, excludedPath) { return false }
+	}
+	return true
+}
+
+// Whether the file at the given path is a symlink to one of selfman's files.
+func (self ManagedDir) IsLinkInto(filePath string) bool {
+	stat, err := os.Lstat(filePath)
+	if err != nil || stat.Mode() & os.ModeSymlink == 0 { return false }
+
+	target, err := run.ReadLinkAbs(filePath)
+	if err != nil { return false }
+	return self.Contains(target)
+}
+
+// Controls how operations which place files outside of selfman's data dir treat existing files
+// which selfman did not create.
+type ClobberPolicy struct {
+	// Replace existing files which selfman did not create, instead of failing
+	Force bool
+	// If non-empty, replaced files which selfman did not create are moved into this directory
+	// instead of being deleted
+	BackupDir string
+}
+
+func (self ClobberPolicy) describe() string {
+	switch {
+	case self.Force && len(self.BackupDir) > 0:
+		return fmt.Sprintf("replace non-selfman files (backed up to %s)", self.BackupDir)
+	case self.Force: return "replace non-selfman files"
+	default: return "refuse to replace non-selfman files"
+	}
+}
+
+// Clears the way for a new file at destPath. An existing symlink into managedDir (or a wrapper
+// script selfman wrote) is always removed, but anything else is only removed (or backed up) if the
+// clobber policy allows it. Directories are only ever backed up, never removed.
+//
+// Returns a message describing any backup which was made.
+func clearLinkDestination(
+	destPath string,
+	managedDir ManagedDir,
+	clobber ClobberPolicy,
+) (string, error) {
+	stat, err := os.Lstat(destPath)
+	if errors.Is(err, os.ErrNotExist) { return "", nil }
+	if err != nil { return "", err }
+
+	if _, managed := ManagedLinkTarget(destPath, managedDir); managed {
+		return "", os.Remove(destPath)
+	}
+
+	if !clobber.Force {
+		return "", fmt.Errorf(
+			"Refusing to replace \"%s\", which was not created by selfman (use --force to " +
+				"replace it)",
+			destPath,
+		)
+	}
+
+	if len(clobber.BackupDir) == 0 {
+		// a whole directory is too much to delete on the strength of --force alone
+		if stat.IsDir() {
+			return "", fmt.Errorf(
+				"Refusing to replace \"%s\", which is a directory not created by selfman (use " +
+					"--force with --backup to move it out of the way)",
+				destPath,
+			)
+		}
+		return "", os.Remove(destPath)
+	}
+
+	err = run.VerifyDirExists(clobber.BackupDir)
+	if err != nil { return "", fmt.Errorf("Could not create backup dir: %w", err) }
+	backupPath := path.Join(
+		clobber.BackupDir,
+		path.Base(destPath) + "." + strconv.FormatInt(time.Now().Unix(), 10),
+	)
+	err = os.Rename(destPath, backupPath)
+	if err != nil { return "", fmt.Errorf("Could not back up existing file: %w", err) }
+	return fmt.Sprintf("backed up existing file to %s", backupPath), nil
+}
+
+func appendDetail(msg string, detail string) string {
+	if len(detail) == 0 { return msg }
+	return msg + " (" + detail + ")"
+}
+
+func createSymlink(sourcePath string, destPath string, relative bool) error {
+	target := sourcePath
+	if relative {
+		relTarget, err := filepath.Rel(path.Dir(destPath), sourcePath)
+		if err != nil { return fmt.Errorf("Could not determine relative link target: %w", err) }
+		target = relTarget
+	}
+	// links may go in dirs which don't exist yet (e.g. for man pages)
+	err := run.VerifyDirExists(path.Dir(destPath))
+	if err != nil { return fmt.Errorf("Could not create dir for link: %w", err) }
+	return os.Symlink(target, destPath)
+}
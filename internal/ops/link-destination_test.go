@@ -0,0 +1,170 @@
+package ops
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+// Laid out the same way as selfman's data dir.
+func testManagedDir(dir string) ManagedDir {
+	return ManagedDir{
+		Path: dir,
+		CopyRecordsPath: path.Join(dir, "meta", "installed-copies.yaml"),
+	}
+}
+
+func TestLinkArtifactReplacesSelfmanLinks(t *testing.T) {
+	managedDir := t.TempDir()
+	binDir := t.TempDir()
+	oldArtifact := path.Join(managedDir, "app---old")
+	newArtifact := path.Join(managedDir, "app---new")
+	run.AssertNoErr(os.WriteFile(oldArtifact, []byte("old"), 0o755))
+	run.AssertNoErr(os.WriteFile(newArtifact, []byte("new"), 0o755))
+	linkPath := path.Join(binDir, "app")
+	run.AssertNoErr(os.Symlink(oldArtifact, linkPath))
+
+	_, err := LinkArtifact{
+		SourcePath: newArtifact,
+		DestinationPath: linkPath,
+		ManagedDir: testManagedDir(managedDir),
+	}.Execute(t.Context())
+	assert.NoError(t, err)
+
+	target, err := os.Readlink(linkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, newArtifact, target)
+}
+
+func TestLinkArtifactRefusesToReplaceForeignFiles(t *testing.T) {
+	managedDir := t.TempDir()
+	binDir := t.TempDir()
+	artifact := path.Join(managedDir, "app---1.0")
+	run.AssertNoErr(os.WriteFile(artifact, []byte("selfman"), 0o755))
+	linkPath := path.Join(binDir, "app")
+	run.AssertNoErr(os.WriteFile(linkPath, []byte("installed by hand"), 0o755))
+
+	op := LinkArtifact{
+		SourcePath: artifact,
+		DestinationPath: linkPath,
+		ManagedDir: testManagedDir(managedDir),
+	}
+	_, err := op.Execute(t.Context())
+	assert.ErrorContains(t, err, "Refusing to replace")
+	contents, err := os.ReadFile(linkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "installed by hand", string(contents))
+
+	backupDir := path.Join(t.TempDir(), "backups")
+	op.Clobber = ClobberPolicy{ Force: true, BackupDir: backupDir }
+	_, err = op.Execute(t.Context())
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	target, err := os.Readlink(linkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, artifact, target)
+
+	backups, err := os.ReadDir(backupDir)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Len(t, backups, 1)
+	contents, err = os.ReadFile(path.Join(backupDir, backups[0].Name()))
+	assert.NoError(t, err)
+	assert.Equal(t, "installed by hand", string(contents))
+}
+
+func TestForceDoesNotDeleteForeignDirs(t *testing.T) {
+	managedDir := t.TempDir()
+	artifact := path.Join(managedDir, "app---1.0")
+	run.AssertNoErr(os.WriteFile(artifact, []byte("selfman"), 0o755))
+	linkPath := path.Join(t.TempDir(), "app")
+	run.AssertNoErr(os.Mkdir(linkPath, 0o755))
+	run.AssertNoErr(os.WriteFile(path.Join(linkPath, "precious"), []byte("keep me"), 0o644))
+
+	op := LinkArtifact{
+		SourcePath: artifact,
+		DestinationPath: linkPath,
+		ManagedDir: testManagedDir(managedDir),
+		Clobber: ClobberPolicy{ Force: true },
+	}
+	_, err := op.Execute(t.Context())
+	assert.ErrorContains(t, err, "which is a directory not created by selfman")
+	assert.FileExists(t, path.Join(linkPath, "precious"))
+
+	backupDir := path.Join(t.TempDir(), "backups")
+	op.Clobber.BackupDir = backupDir
+	_, err = op.Execute(t.Context())
+	assert.NoError(t, err)
+	backups, err := os.ReadDir(backupDir)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	assert.Len(t, backups, 1)
+	assert.FileExists(t, path.Join(backupDir, backups[0].Name(), "precious"))
+}
+
+func TestRelativeLinksResolveToArtifact(t *testing.T) {
+	rootDir := t.TempDir()
+	managedDir := path.Join(rootDir, "data")
+	binDir := path.Join(rootDir, "bin")
+	run.AssertNoErr(os.MkdirAll(managedDir, 0o755))
+	run.AssertNoErr(os.MkdirAll(binDir, 0o755))
+	artifact := path.Join(managedDir, "app---1.0")
+	run.AssertNoErr(os.WriteFile(artifact, []byte("selfman"), 0o755))
+	linkPath := path.Join(binDir, "app")
+
+	_, err := LinkArtifact{
+		SourcePath: artifact,
+		DestinationPath: linkPath,
+		ManagedDir: testManagedDir(managedDir),
+		Relative: true,
+	}.Execute(t.Context())
+	assert.NoError(t, err)
+
+	target, err := os.Readlink(linkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "../data/app---1.0", target)
+
+	resolvedTarget, managed := ManagedLinkTarget(linkPath, testManagedDir(managedDir))
+	assert.True(t, managed)
+	assert.Equal(t, artifact, resolvedTarget)
+}
+
+func TestCopiesAreRecognizedUntilModified(t *testing.T) {
+	managedDir := t.TempDir()
+	binDir := t.TempDir()
+	oldArtifact := path.Join(managedDir, "app---old")
+	newArtifact := path.Join(managedDir, "app---new")
+	run.AssertNoErr(os.WriteFile(oldArtifact, []byte("old"), 0o750))
+	run.AssertNoErr(os.WriteFile(newArtifact, []byte("new"), 0o750))
+	copyPath := path.Join(binDir, "app")
+
+	for _, artifact := range []string{ oldArtifact, newArtifact } {
+		_, err := CopyArtifact{
+			SourcePath: artifact,
+			DestinationPath: copyPath,
+			ManagedDir: testManagedDir(managedDir),
+		}.Execute(t.Context())
+		assert.NoError(t, err, "Earlier copies placed by selfman are safe to replace")
+		run.BailIfFailed(t)
+	}
+
+	stat, err := os.Lstat(copyPath)
+	assert.NoError(t, err)
+	assert.True(t, stat.Mode().IsRegular())
+	assert.Equal(t, os.FileMode(0o750), stat.Mode().Perm())
+	contents, err := os.ReadFile(copyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(contents))
+
+	target, managed := ManagedLinkTarget(copyPath, testManagedDir(managedDir))
+	assert.True(t, managed)
+	assert.Equal(t, newArtifact, target)
+
+	run.AssertNoErr(os.WriteFile(copyPath, []byte("edited by hand"), 0o750))
+	_, managed = ManagedLinkTarget(copyPath, testManagedDir(managedDir))
+	assert.False(t, managed, "A copy which has since been changed is no longer selfman's")
+}
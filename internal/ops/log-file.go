@@ -0,0 +1,59 @@
+package ops
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Log file names start with the time they were created in this format, so that sorting them by
+// name also sorts them by age.
+const LogTimestampFormat = "2006-01-02T15-04-05.000"
+
+// The path for a new log file in the given dir, holding the output of the given kind of
+// operation. If the log dir is empty, the operation's output is not logged, and neither is the
+// returned path.
+func newLogPath(logDir, kind string) string {
+	if len(logDir) == 0 { return "" }
+	return path.Join(logDir, time.Now().Format(LogTimestampFormat) + "-" + kind + ".log")
+}
+
+// The kind of log holding an app's build output, along with the output of its hooks.
+const buildLogKind = "build"
+
+// Everything logged under the build kind while selfman runs goes into one log file per log dir, so
+// that a build's commands and the hooks around it can be read together.
+var buildLogs = struct {
+	sync.Mutex
+	// log dir -> log file path
+	paths map[string]string
+}{ paths: make(map[string]string) }
+
+func buildLogPath(logDir string) string {
+	if len(logDir) == 0 { return "" }
+
+	buildLogs.Lock()
+	defer buildLogs.Unlock()
+	logPath, present := buildLogs.paths[logDir]
+	if !present {
+		logPath = newLogPath(logDir, buildLogKind)
+		buildLogs.paths[logDir] = logPath
+	}
+	return logPath
+}
+
+// Output for an app's commands: logged to a new file in the log dir (if set), and streamed with
+// the app's name as the prefix. Output of the build kind is added to this run's build log instead
+// of a new file.
+func appCmdOutput(appName, logDir, kind string) run.CmdOutput {
+	logPath := newLogPath(logDir, kind)
+	if kind == buildLogKind {
+		logPath = buildLogPath(logDir)
+	}
+	return run.CmdOutput{
+		LogPath: logPath,
+		StreamPrefix: appName,
+	}
+}
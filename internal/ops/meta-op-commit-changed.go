@@ -1,6 +1,7 @@
 package ops
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -13,7 +14,7 @@ type MetaOpCommitChanged struct {
 	IfChangedOps []Operation
 }
 
-func (self MetaOpCommitChanged) Execute() (string, error) {
+func (self MetaOpCommitChanged) Execute(ctx context.Context) (string, error) {
 	hash, err := git.CurrentHeadCommit(self.RepoPath)
 	if err != nil { return "", fmt.Errorf("Determining current HEAD commit failed: %w", err) }
 
@@ -23,8 +24,12 @@ func (self MetaOpCommitChanged) Execute() (string, error) {
 
 	var output strings.Builder
 	output.WriteString("New commit hash detected, executing conditional operations...")
-	for _, op := range self.IfChangedOps {
-		opOutput, err := op.Execute()
+	for i, op := range self.IfChangedOps {
+		if i > 0 && ctx.Err() != nil {
+			return output.String(), InterruptedAfter(ctx, self.IfChangedOps[i - 1])
+		}
+		opOutput, err := op.Execute(ctx)
+		err = AttributeInterruption(ctx, op, err)
 		if err != nil {
 			output.WriteString("\nStep failed")
 			if len(opOutput) > 0 {
@@ -1,7 +1,9 @@
 package ops
 
 import (
+	"context"
 	"fmt"
+	"path"
 
 	"github.com/lorentzforces/selfman/internal/run"
 )
@@ -11,8 +13,13 @@ type MoveTarget struct {
 	DestinationPath string
 }
 
-func (self MoveTarget) Execute() (string, error) {
-	err := run.MoveFile(self.SourcePath, self.DestinationPath)
+func (self MoveTarget) Execute(ctx context.Context) (string, error) {
+	// apps with multiple targets keep their artifacts in a dir per version
+	err := run.VerifyDirExists(path.Dir(self.DestinationPath))
+	if err != nil { return "", fmt.Errorf("Error creating parent dir for target: %w", err) }
+
+	// rebuilding a version replaces the artifact it had before
+	err = run.MoveReplacing(self.SourcePath, self.DestinationPath)
 	if err != nil {
 		return "", fmt.Errorf("Target move failed: %w", err)
 	}
@@ -1,13 +1,16 @@
 package ops
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type NoOp struct {
 	TypeOfNoOp string
 	Description string
 }
 
-func (self NoOp) Execute() (string, error) {
+func (self NoOp) Execute(ctx context.Context) (string, error) {
 	return "Successfully did nothing", nil
 }
 
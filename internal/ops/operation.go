@@ -3,6 +3,7 @@
 package ops
 
 import (
+	"context"
 	"strings"
 
 	"github.com/lorentzforces/selfman/internal/run"
@@ -18,7 +19,11 @@ type Operation interface {
 	// Execute the operation. If an error is returned, the operation has failed, and any context
 	// should be included in the error itself. If err is non-nil, then msg should contain no useful
 	// information and should be disregarded.
-	Execute() (msg string, err error)
+	//
+	// Operations which run external commands or go over the network stop them when ctx is
+	// cancelled. Operations which only change local files ignore ctx and run to completion, so that
+	// cancellation never leaves a half-moved file or half-replaced link behind.
+	Execute(ctx context.Context) (msg string, err error)
 
 	// A human-readable description of what the operation will do when executed. Should include
 	// context such as file names, destinations, etc.
@@ -0,0 +1,84 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+
+	"github.com/lorentzforces/selfman/internal/git"
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Runs one of an app's hook commands (e.g. its post-build hook).
+type RunHook struct {
+	// The hook's name, e.g. "post-build"
+	Hook string
+	ScriptShell string
+	Cmd string
+	// The app's build environment
+	Env run.Env
+	// Variables describing the app, set for the hook on top of its environment
+	AppVars map[string]string
+	// If set, the commit checked out in this repo is put in the hook's environment when it runs (it
+	// may not be known until the operations before the hook have run), otherwise it is left empty
+	CommitRepoPath string
+	// The hook runs in this dir, or in FallbackDir if it doesn't exist (e.g. for a pre-remove hook
+	// when the app's source is already gone)
+	WorkingDir string
+	FallbackDir string
+	LogDir string
+	AppName string
+}
+
+// The name of the variable holding the commit an app's hook is run for.
+const HookCommitVar = "SELFMAN_COMMIT"
+
+func (self RunHook) Execute(ctx context.Context) (string, error) {
+	env := self.Env
+	env.Vars = maps.Clone(env.Vars)
+	if env.Vars == nil {
+		env.Vars = make(map[string]string, len(self.AppVars) + 1)
+	}
+	maps.Copy(env.Vars, self.AppVars)
+	env.Vars[HookCommitVar] = ""
+	if len(self.CommitRepoPath) > 0 {
+		commit, err := git.CurrentHeadCommit(self.CommitRepoPath)
+		if err != nil {
+			return "", fmt.Errorf("Error reading commit for %s hook: %w", self.Hook, err)
+		}
+		env.Vars[HookCommitVar] = commit
+	}
+
+	workingDir := self.WorkingDir
+	if stat, err := os.Stat(workingDir); err != nil || !stat.IsDir() {
+		workingDir = self.FallbackDir
+	}
+
+	// hooks are part of the build as far as the user is concerned, so they share its log
+	_, err := run.NewCmd(
+		self.ScriptShell,
+		run.WithArgs("-c", self.Cmd),
+		run.WithContext(ctx),
+		run.WithWorkingDir(workingDir),
+		run.WithEnvironment(env),
+		run.WithOutput(appCmdOutput(self.AppName, self.LogDir, buildLogKind)),
+	).Exec()
+	if err != nil { return "", fmt.Errorf("Error while running %s hook: %w", self.Hook, err) }
+
+	return fmt.Sprintf("Ran %s hook", self.Hook), nil
+}
+
+func (self RunHook) Describe() OpDescription {
+	contextLines := []string{
+		fmt.Sprintf("shell: %s -c", self.ScriptShell),
+		fmt.Sprintf("hook command: %s", self.Cmd),
+		fmt.Sprintf("working dir: %s (or %s)", self.WorkingDir, self.FallbackDir),
+	}
+	contextLines = append(contextLines, describeEnv(self.Env)...)
+
+	return OpDescription{
+		TopLine: fmt.Sprintf("Run app %s hook", self.Hook),
+		ContextLines: contextLines,
+	}
+}
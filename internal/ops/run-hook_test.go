@@ -0,0 +1,79 @@
+package ops
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHookRunsWithAppVarsInBuildLog(t *testing.T) {
+	logDir := t.TempDir()
+	sourceDir := t.TempDir()
+	build := BuildWithScript{
+		SourcePath: sourceDir,
+		ScriptShell: "/bin/sh",
+		ScriptCmd: "echo building",
+		LogDir: logDir,
+		AppName: "hooked",
+	}
+	hook := RunHook{
+		Hook: "post-build",
+		ScriptShell: "/bin/sh",
+		Cmd: "echo \"$SELFMAN_APP_NAME $RELEASE commit=[$SELFMAN_COMMIT] in $PWD\"",
+		Env: run.Env{ Vars: map[string]string{ "RELEASE": "stable" } },
+		AppVars: map[string]string{ "SELFMAN_APP_NAME": "hooked" },
+		WorkingDir: sourceDir,
+		FallbackDir: logDir,
+		LogDir: logDir,
+		AppName: "hooked",
+	}
+
+	_, err := build.Execute(t.Context())
+	assert.NoError(t, err)
+	msg, err := hook.Execute(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, "Ran post-build hook", msg)
+
+	logFiles, err := os.ReadDir(logDir)
+	assert.NoError(t, err)
+	assert.Len(t, logFiles, 1, "The hook's output goes in the build log")
+	run.BailIfFailed(t)
+	assert.Regexp(t, `-build\.log$`, logFiles[0].Name())
+
+	contents, err := os.ReadFile(path.Join(logDir, logFiles[0].Name()))
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "building\n")
+	assert.Contains(
+		t,
+		string(contents),
+		"hooked stable commit=[] in " + sourceDir + "\n=== finished: success\n",
+	)
+}
+
+func TestHookRunsInFallbackDirWithoutSource(t *testing.T) {
+	fallbackDir := t.TempDir()
+	hook := RunHook{
+		Hook: "pre-remove",
+		ScriptShell: "/bin/sh",
+		Cmd: "test \"$PWD\" = " + run.ShellQuote(fallbackDir),
+		WorkingDir: path.Join(fallbackDir, "removed-source"),
+		FallbackDir: fallbackDir,
+	}
+
+	_, err := hook.Execute(t.Context())
+	assert.NoError(t, err)
+}
+
+func TestFailedHookNamesHook(t *testing.T) {
+	hook := RunHook{
+		Hook: "pre-remove",
+		ScriptShell: "/bin/sh",
+		Cmd: "exit 4",
+	}
+
+	_, err := hook.Execute(t.Context())
+	assert.ErrorContains(t, err, "Error while running pre-remove hook")
+}
@@ -0,0 +1,90 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Copies one of an app's extra files (man pages, completions, etc.) out of its build into
+// selfman's data dir, so it outlives changes to the source.
+type StoreExtraFile struct {
+	SourcePath string
+	DestinationPath string
+}
+
+func (self StoreExtraFile) Execute(ctx context.Context) (string, error) {
+	err := run.VerifyDirExists(path.Dir(self.DestinationPath))
+	if err != nil { return "", fmt.Errorf("Error creating dir for extra file: %w", err) }
+
+	err = copyFilePreservingMode(self.SourcePath, self.DestinationPath)
+	if err != nil { return "", fmt.Errorf("Storing extra file failed: %w", err) }
+	return "Stored extra file", nil
+}
+
+func (self StoreExtraFile) Describe() OpDescription {
+	return OpDescription{
+		TopLine: "Store app extra file",
+		ContextLines: []string{
+			fmt.Sprintf("from: %s", self.SourcePath),
+			fmt.Sprintf("to: %s", self.DestinationPath),
+		},
+	}
+}
+
+// Runs a command and stores its output as one of an app's extra files (e.g. for tools which print
+// their own shell completions).
+type CaptureExtraFile struct {
+	ScriptShell string
+	ScriptCmd string
+	WorkingDir string
+	// Made available to the command as $SELFMAN_ARTIFACT
+	ArtifactPath string
+	DestinationPath string
+	// The app's build environment
+	Env run.Env
+	LogDir string
+	AppName string
+}
+
+const artifactEnvVar = "SELFMAN_ARTIFACT"
+
+func (self CaptureExtraFile) Execute(ctx context.Context) (string, error) {
+	output, err := run.NewCmd(
+		self.ScriptShell,
+		run.WithArgs("-c", self.ScriptCmd),
+		run.WithContext(ctx),
+		run.WithWorkingDir(self.WorkingDir),
+		run.WithEnvironment(self.Env),
+		run.WithEnv(artifactEnvVar, self.ArtifactPath),
+		run.WithOutput(appCmdOutput(self.AppName, self.LogDir, "extra-file")),
+	).Exec()
+	if err != nil {
+		return "", fmt.Errorf("Error while running command for extra file: %w", err)
+	}
+
+	err = run.VerifyDirExists(path.Dir(self.DestinationPath))
+	if err != nil { return "", fmt.Errorf("Error creating dir for extra file: %w", err) }
+
+	err = os.WriteFile(self.DestinationPath, []byte(output), 0o644)
+	if err != nil { return "", fmt.Errorf("Storing extra file failed: %w", err) }
+	return "Captured extra file", nil
+}
+
+func (self CaptureExtraFile) Describe() OpDescription {
+	contextLines := []string{
+		fmt.Sprintf("shell: %s -c", self.ScriptShell),
+		fmt.Sprintf("command: %s", self.ScriptCmd),
+		fmt.Sprintf("working dir: %s", self.WorkingDir),
+		fmt.Sprintf("$%s: %s", artifactEnvVar, self.ArtifactPath),
+		fmt.Sprintf("to: %s", self.DestinationPath),
+	}
+
+	return OpDescription{
+		TopLine: "Capture app extra file from command output",
+		ContextLines: append(contextLines, describeEnv(self.Env)...),
+	}
+}
@@ -0,0 +1,93 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+// Placed in a verify command where the path of the artifact under test goes.
+const ArtifactPlaceholder = "%ARTIFACT%"
+
+// Smoke-tests a newly built artifact before it replaces the one in use, by running a command
+// against it.
+type VerifyArtifact struct {
+	// The file the command runs against (inside the artifact, for directory artifacts)
+	ArtifactPath string
+	// The staged build, removed if verification fails. This is never the artifact currently in use,
+	// which stays linked as it was.
+	DiscardPath string
+	ScriptShell string
+	// Run with the script shell, with the artifact placeholder replaced by the quoted artifact path
+	Cmd string
+	// If set, the command's output (stdout and stderr) must match this regex. ^ and $ match at the
+	// start and end of each line.
+	ExpectedOutput string
+	LogDir string
+	AppName string
+}
+
+// Verify commands are meant to exit right away, anything still running after this is stuck (e.g.
+// an app which ignored its arguments and started up for real).
+const verifyCmdTimeout = time.Minute
+
+func (self VerifyArtifact) Execute(ctx context.Context) (string, error) {
+	cmd := strings.ReplaceAll(self.Cmd, ArtifactPlaceholder, run.ShellQuote(self.ArtifactPath))
+	// plenty of programs print their version to stderr, so both are checked against the pattern
+	output, err := run.NewCmd(
+		self.ScriptShell,
+		run.WithArgs("-c", "exec 2>&1\n" + cmd),
+		run.WithContext(ctx),
+		run.WithTimeout(verifyCmdTimeout, "the verify-cmd time limit"),
+		run.WithOutput(appCmdOutput(self.AppName, self.LogDir, "verify")),
+	).Exec()
+	if err != nil {
+		return "", self.discard(fmt.Errorf("Verification command failed: %w", err))
+	}
+
+	if len(self.ExpectedOutput) > 0 {
+		pattern, err := regexp.Compile("(?m)" + self.ExpectedOutput)
+		if err != nil { return "", fmt.Errorf("Invalid expected output pattern: %w", err) }
+		if nil == pattern.FindStringIndex(output) {
+			return "", self.discard(fmt.Errorf(
+				"Verification output did not match /%s/\nOUTPUT:\n%s",
+				self.ExpectedOutput,
+				strings.TrimRight(output, "\n"),
+			))
+		}
+	}
+
+	return "Verified artifact", nil
+}
+
+func (self VerifyArtifact) discard(verifyErr error) error {
+	if len(self.DiscardPath) == 0 { return verifyErr }
+	err := os.RemoveAll(self.DiscardPath)
+	if err != nil {
+		return fmt.Errorf("%w\nError removing failed artifact: %w", verifyErr, err)
+	}
+	return fmt.Errorf("%w\nRemoved failed artifact: %s", verifyErr, self.DiscardPath)
+}
+
+func (self VerifyArtifact) Describe() OpDescription {
+	contextLines := []string{
+		fmt.Sprintf("artifact: %s", self.ArtifactPath),
+		fmt.Sprintf("cmd: %s", self.Cmd),
+	}
+	if len(self.ExpectedOutput) > 0 {
+		contextLines = append(
+			contextLines,
+			fmt.Sprintf("expected output: /%s/", self.ExpectedOutput),
+		)
+	}
+
+	return OpDescription{
+		TopLine: "Verify app artifact",
+		ContextLines: contextLines,
+	}
+}
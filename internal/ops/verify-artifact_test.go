@@ -0,0 +1,63 @@
+package ops
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFakeArtifact(t *testing.T, script string) string {
+	// a space in the path checks that the artifact path is quoted in the command
+	artifactPath := path.Join(t.TempDir(), "my app")
+	err := os.WriteFile(artifactPath, []byte("#!/bin/sh\n" + script), 0o755)
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+	return artifactPath
+}
+
+func TestVerifyPassesWhenOutputMatches(t *testing.T) {
+	artifactPath := writeFakeArtifact(t, "echo \"myapp version $1\" >&2")
+	verify := VerifyArtifact{
+		ArtifactPath: artifactPath,
+		DiscardPath: artifactPath,
+		ScriptShell: "/bin/sh",
+		Cmd: "%ARTIFACT% 1.2.3",
+		ExpectedOutput: `version 1\.2\.3$`,
+	}
+
+	_, err := verify.Execute(t.Context())
+	assert.NoError(t, err, "Output to stderr is matched too")
+	assert.FileExists(t, artifactPath)
+}
+
+func TestFailedVerifyDiscardsArtifact(t *testing.T) {
+	artifactPath := writeFakeArtifact(t, "echo 'myapp version 0.9'")
+	verify := VerifyArtifact{
+		ArtifactPath: artifactPath,
+		DiscardPath: artifactPath,
+		ScriptShell: "/bin/sh",
+		Cmd: "%ARTIFACT% --version",
+		ExpectedOutput: `version 1\.0`,
+	}
+
+	_, err := verify.Execute(t.Context())
+	assert.ErrorContains(t, err, "Verification output did not match")
+	assert.ErrorContains(t, err, "OUTPUT:\nmyapp version 0.9\n")
+	assert.ErrorContains(t, err, "Removed failed artifact: " + artifactPath)
+	assert.NoFileExists(t, artifactPath)
+
+	crashingPath := writeFakeArtifact(t, "exit 1")
+	verify = VerifyArtifact{
+		ArtifactPath: crashingPath,
+		DiscardPath: crashingPath,
+		ScriptShell: "/bin/sh",
+		Cmd: "%ARTIFACT% --version",
+	}
+
+	_, err = verify.Execute(t.Context())
+	assert.ErrorContains(t, err, "Verification command failed")
+	assert.NoFileExists(t, crashingPath)
+}
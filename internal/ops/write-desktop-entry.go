@@ -0,0 +1,106 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Every desktop entry selfman writes starts with this line, which is how selfman recognizes
+// desktop entries it owns.
+const desktopEntryMarkerLine =
+	"# selfman desktop entry - generated, any changes will be overwritten"
+
+// A freedesktop.org desktop entry, which makes an app show up in desktop environments' launchers.
+type DesktopEntry struct {
+	Name string
+	Comment string
+	ExecPath string
+	// If empty, the entry has no icon
+	IconPath string
+	Categories []string
+	Terminal bool
+}
+
+func (self DesktopEntry) Contents() string {
+	var buf strings.Builder
+	buf.WriteString(desktopEntryMarkerLine + "\n")
+	buf.WriteString("[Desktop Entry]\n")
+	buf.WriteString("Type=Application\n")
+	buf.WriteString("Name=" + escapeDesktopValue(self.Name) + "\n")
+	if len(self.Comment) > 0 {
+		buf.WriteString("Comment=" + escapeDesktopValue(self.Comment) + "\n")
+	}
+	buf.WriteString("Exec=" + escapeDesktopValue(quoteExecArg(self.ExecPath)) + "\n")
+	if len(self.IconPath) > 0 {
+		buf.WriteString("Icon=" + escapeDesktopValue(self.IconPath) + "\n")
+	}
+	buf.WriteString(fmt.Sprintf("Terminal=%t\n", self.Terminal))
+	if len(self.Categories) > 0 {
+		buf.WriteString("Categories=" + strings.Join(self.Categories, ";") + ";\n")
+	}
+	return buf.String()
+}
+
+// Escapes the characters which cannot appear literally in a desktop entry string value.
+func escapeDesktopValue(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"\n", `\n`,
+		"\t", `\t`,
+		"\r", `\r`,
+	).Replace(value)
+}
+
+// Quotes an argument of a desktop entry's Exec key if it contains any reserved characters. Literal
+// percent signs are always doubled, since they would otherwise be taken as field codes.
+func quoteExecArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") { return arg }
+
+	escaped := strings.NewReplacer(
+		`"`, `\"`,
+		"`", "\\`",
+		`$`, `\$`,
+		`\`, `\\`,
+	).Replace(arg)
+	return `"` + escaped + `"`
+}
+
+// Whether the file at the given path is a desktop entry written by selfman.
+func IsSelfmanDesktopEntry(filePath string) bool {
+	return hasMarkerLine(filePath, desktopEntryMarkerLine)
+}
+
+type WriteDesktopEntry struct {
+	Entry DesktopEntry
+	DestinationPath string
+	ManagedDir ManagedDir
+	Clobber ClobberPolicy
+}
+
+func (self WriteDesktopEntry) Execute(ctx context.Context) (string, error) {
+	backupMsg, err := writeGeneratedFile(
+		self.DestinationPath,
+		self.Entry.Contents(),
+		desktopEntryMarkerLine,
+		self.ManagedDir,
+		self.Clobber,
+	)
+	if err != nil { return "", fmt.Errorf("Writing desktop entry failed: %w", err) }
+	return appendDetail("Wrote desktop entry", backupMsg), nil
+}
+
+func (self WriteDesktopEntry) Describe() OpDescription {
+	contextLines := []string{
+		fmt.Sprintf("at: %s", self.DestinationPath),
+		fmt.Sprintf("existing files: %s", self.Clobber.describe()),
+		"contents:",
+	}
+	contextLines = append(contextLines, indentedLines(self.Entry.Contents())...)
+
+	return OpDescription{
+		TopLine: "Write app desktop entry",
+		ContextLines: contextLines,
+	}
+}
@@ -0,0 +1,59 @@
+package ops
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDesktopEntryQuotesExecPath(t *testing.T) {
+	entry := DesktopEntry{
+		Name: "Some App",
+		ExecPath: "/home/me/my bin/app%1",
+		IconPath: "/home/me/.local/share/icons/app.png",
+		Categories: []string{ "Utility", "Development" },
+	}
+
+	assert.Equal(
+		t,
+		desktopEntryMarkerLine + "\n" +
+			"[Desktop Entry]\n" +
+			"Type=Application\n" +
+			"Name=Some App\n" +
+			"Exec=\"/home/me/my bin/app%%1\"\n" +
+			"Icon=/home/me/.local/share/icons/app.png\n" +
+			"Terminal=false\n" +
+			"Categories=Utility;Development;\n",
+		entry.Contents(),
+	)
+	assert.Equal(t, `"a\\\\b \\$c"`, escapeDesktopValue(quoteExecArg(`a\b $c`)))
+}
+
+func TestWriteDesktopEntryOnlyReplacesItsOwnEntries(t *testing.T) {
+	managedDir := t.TempDir()
+	entryPath := path.Join(t.TempDir(), "applications", "app.desktop")
+	writeOp := WriteDesktopEntry{
+		Entry: DesktopEntry{ Name: "app", ExecPath: "/bin/app" },
+		DestinationPath: entryPath,
+		ManagedDir: testManagedDir(managedDir),
+	}
+
+	_, err := writeOp.Execute(t.Context())
+	assert.NoError(t, err)
+	assert.True(t, IsSelfmanDesktopEntry(entryPath))
+
+	writeOp.Entry.Comment = "updated"
+	_, err = writeOp.Execute(t.Context())
+	assert.NoError(t, err, "Selfman's own desktop entries are always replaced")
+	contents, err := os.ReadFile(entryPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(contents), "Comment=updated\n")
+
+	run.AssertNoErr(os.WriteFile(entryPath, []byte("[Desktop Entry]\n"), 0o644))
+	assert.False(t, IsSelfmanDesktopEntry(entryPath))
+	_, err = writeOp.Execute(t.Context())
+	assert.Error(t, err, "Desktop entries selfman did not write must not be replaced by default")
+}
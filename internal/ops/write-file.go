@@ -0,0 +1,68 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+type WriteFile struct {
+	TypeOfFile string
+	Path string
+	Contents string
+	Mode os.FileMode
+	// If false, the operation fails if a file already exists at the path
+	Overwrite bool
+}
+
+func (self WriteFile) Execute(ctx context.Context) (string, error) {
+	err := run.VerifyDirExists(path.Dir(self.Path))
+	if err != nil { return "", fmt.Errorf("Error creating parent dir for file: %w", err) }
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !self.Overwrite {
+		flags |= os.O_EXCL
+	}
+	file, err := os.OpenFile(self.Path, flags, self.Mode)
+	if errors.Is(err, os.ErrExist) {
+		return "", fmt.Errorf("Refusing to overwrite existing file: %s", self.Path)
+	}
+	if err != nil { return "", fmt.Errorf("Writing file failed: %w", err) }
+	defer file.Close()
+
+	_, err = file.WriteString(self.Contents)
+	if err != nil { return "", fmt.Errorf("Writing file failed: %w", err) }
+
+	// the mode given to OpenFile is only applied to new files (and is subject to the umask)
+	err = file.Chmod(self.Mode)
+	if err != nil { return "", fmt.Errorf("Setting file permissions failed: %w", err) }
+
+	return fmt.Sprintf("Wrote %s", self.TypeOfFile), nil
+}
+
+func (self WriteFile) Describe() OpDescription {
+	contextLines := []string{
+		fmt.Sprintf("path: %s", self.Path),
+		fmt.Sprintf("permissions: %s", self.Mode),
+		"contents:",
+	}
+	contextLines = append(contextLines, indentedLines(self.Contents)...)
+
+	return OpDescription{
+		TopLine: fmt.Sprintf("Write %s", self.TypeOfFile),
+		ContextLines: contextLines,
+	}
+}
+
+func indentedLines(text string) []string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = run.IndentChars + line
+	}
+	return lines
+}
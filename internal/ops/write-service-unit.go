@@ -0,0 +1,121 @@
+package ops
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Every service unit selfman writes starts with this line, which is how selfman recognizes units
+// it owns.
+const serviceUnitMarkerLine =
+	"# selfman service unit - generated, any changes will be overwritten"
+
+// A systemd user service unit which runs an app as a long-running service.
+type ServiceUnit struct {
+	Description string
+	ExecPath string
+	Args []string
+	// Environment variables to set (values are used literally)
+	Env map[string]string
+	// If empty, systemd's default (the user's home dir) is used
+	WorkingDir string
+	Restart string
+	WantedBy string
+}
+
+func (self ServiceUnit) Contents() string {
+	var buf strings.Builder
+	buf.WriteString(serviceUnitMarkerLine + "\n")
+	buf.WriteString("[Unit]\n")
+	// specifiers are expanded in descriptions too
+	buf.WriteString("Description=" + strings.ReplaceAll(self.Description, "%", "%%") + "\n")
+	buf.WriteString("\n")
+
+	buf.WriteString("[Service]\n")
+	buf.WriteString("Type=simple\n")
+	buf.WriteString("ExecStart=" + quoteUnitArg(self.ExecPath, true))
+	for _, arg := range self.Args {
+		buf.WriteString(" " + quoteUnitArg(arg, true))
+	}
+	buf.WriteString("\n")
+	if len(self.WorkingDir) > 0 {
+		buf.WriteString("WorkingDirectory=" + quoteUnitArg(self.WorkingDir, false) + "\n")
+	}
+
+	envNames := make([]string, 0, len(self.Env))
+	for name := range self.Env {
+		envNames = append(envNames, name)
+	}
+	slices.Sort(envNames)
+	for _, name := range envNames {
+		buf.WriteString(
+			"Environment=" + quoteUnitArg(name + "=" + self.Env[name], false) + "\n",
+		)
+	}
+
+	buf.WriteString("Restart=" + self.Restart + "\n")
+	buf.WriteString("\n")
+
+	buf.WriteString("[Install]\n")
+	buf.WriteString("WantedBy=" + self.WantedBy + "\n")
+	return buf.String()
+}
+
+// Quotes a value in a systemd unit if needed. Percent signs are always doubled, since they would
+// otherwise be taken as specifiers. In ExecStart (but not elsewhere), dollar signs would be taken
+// as variable references, and are doubled too.
+func quoteUnitArg(arg string, isExecArg bool) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if isExecArg {
+		arg = strings.ReplaceAll(arg, "$", "$$")
+	}
+	if len(arg) > 0 && !strings.ContainsAny(arg, " \t\n\"'\\;") { return arg }
+
+	escaped := strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+		"\t", `\t`,
+	).Replace(arg)
+	return `"` + escaped + `"`
+}
+
+// Whether the file at the given path is a service unit written by selfman.
+func IsSelfmanServiceUnit(filePath string) bool {
+	return hasMarkerLine(filePath, serviceUnitMarkerLine)
+}
+
+type WriteServiceUnit struct {
+	Unit ServiceUnit
+	DestinationPath string
+	ManagedDir ManagedDir
+	Clobber ClobberPolicy
+}
+
+func (self WriteServiceUnit) Execute(ctx context.Context) (string, error) {
+	backupMsg, err := writeGeneratedFile(
+		self.DestinationPath,
+		self.Unit.Contents(),
+		serviceUnitMarkerLine,
+		self.ManagedDir,
+		self.Clobber,
+	)
+	if err != nil { return "", fmt.Errorf("Writing service unit failed: %w", err) }
+	return appendDetail("Wrote service unit", backupMsg), nil
+}
+
+func (self WriteServiceUnit) Describe() OpDescription {
+	contextLines := []string{
+		fmt.Sprintf("at: %s", self.DestinationPath),
+		fmt.Sprintf("existing files: %s", self.Clobber.describe()),
+		"contents:",
+	}
+	contextLines = append(contextLines, indentedLines(self.Unit.Contents())...)
+
+	return OpDescription{
+		TopLine: "Write app service unit",
+		ContextLines: contextLines,
+	}
+}
@@ -0,0 +1,36 @@
+package ops
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceUnitEscapesSpecifiersAndQuotesArgs(t *testing.T) {
+	unit := ServiceUnit{
+		Description: "Sync daemon (100% local)",
+		ExecPath: "/home/me/.local/bin/syncd",
+		Args: []string{ "--dir", "/home/me/my files", "--user=$USER" },
+		Env: map[string]string{ "SYNC_MODE": "fast", "A_FIRST": "1" },
+		Restart: "on-failure",
+		WantedBy: "default.target",
+	}
+
+	assert.Equal(
+		t,
+		serviceUnitMarkerLine + "\n" +
+			"[Unit]\n" +
+			"Description=Sync daemon (100%% local)\n" +
+			"\n" +
+			"[Service]\n" +
+			"Type=simple\n" +
+			"ExecStart=/home/me/.local/bin/syncd --dir \"/home/me/my files\" --user=$$USER\n" +
+			"Environment=A_FIRST=1\n" +
+			"Environment=SYNC_MODE=fast\n" +
+			"Restart=on-failure\n" +
+			"\n" +
+			"[Install]\n" +
+			"WantedBy=default.target\n",
+		unit.Contents(),
+	)
+}
@@ -0,0 +1,143 @@
+package ops
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/lorentzforces/selfman/internal/run"
+)
+
+const (
+	// Every wrapper script selfman writes has this as its second line (after the shebang), which is
+	// how selfman recognizes wrappers it owns.
+	wrapperMarkerLine = "# selfman wrapper script - generated, any changes will be overwritten"
+	// The line following the marker records the artifact the wrapper runs.
+	wrapperArtifactPrefix = "# wraps: "
+)
+
+// A small shell script which runs an artifact with a prepared environment, used instead of a
+// symlink for apps which need one.
+type WrapperScript struct {
+	ArtifactPath string
+	// Environment variables to export (values are used literally, without shell expansion)
+	Env map[string]string
+	// If non-empty, the directory to change to before running the artifact
+	WorkingDir string
+	// Arguments passed to the artifact ahead of any arguments the wrapper was called with
+	Args []string
+}
+
+func (self WrapperScript) Contents() string {
+	var buf strings.Builder
+	buf.WriteString("#!/bin/sh\n")
+	buf.WriteString(wrapperMarkerLine + "\n")
+	buf.WriteString(wrapperArtifactPrefix + self.ArtifactPath + "\n")
+
+	envNames := make([]string, 0, len(self.Env))
+	for name := range self.Env {
+		envNames = append(envNames, name)
+	}
+	slices.Sort(envNames)
+	for _, name := range envNames {
+		buf.WriteString(fmt.Sprintf("export %s=%s\n", name, run.ShellQuote(self.Env[name])))
+	}
+
+	if len(self.WorkingDir) > 0 {
+		buf.WriteString(fmt.Sprintf("cd %s || exit 1\n", run.ShellQuote(self.WorkingDir)))
+	}
+
+	buf.WriteString("exec " + run.ShellQuote(self.ArtifactPath))
+	for _, arg := range self.Args {
+		buf.WriteString(" " + run.ShellQuote(arg))
+	}
+	buf.WriteString(" \"$@\"\n")
+
+	return buf.String()
+}
+
+// If the file at the given path is a wrapper script written by selfman, returns the path of the
+// artifact it runs.
+func ReadWrapperArtifact(filePath string) (string, bool) {
+	stat, err := os.Lstat(filePath)
+	if err != nil || !stat.Mode().IsRegular() { return "", false }
+
+	file, err := os.Open(filePath)
+	if err != nil { return "", false }
+	defer file.Close()
+
+	header := make([]string, 0, 3)
+	scanner := bufio.NewScanner(file)
+	for len(header) < 3 && scanner.Scan() {
+		header = append(header, scanner.Text())
+	}
+	if len(header) < 3 || header[1] != wrapperMarkerLine { return "", false }
+	if !strings.HasPrefix(header[2], wrapperArtifactPrefix) { return "", false }
+
+	return strings.TrimPrefix(header[2], wrapperArtifactPrefix), true
+}
+
+// If selfman placed the file at the given path (a symlink into the managed dir, a wrapper script
+// running something in it, or a recorded copy of an artifact), returns the path the file points
+// to.
+func ManagedLinkTarget(filePath string, managedDir ManagedDir) (string, bool) {
+	if managedDir.IsLinkInto(filePath) {
+		target, err := run.ReadLinkAbs(filePath)
+		if err != nil { return "", false }
+		return target, true
+	}
+
+	artifactPath, isWrapper := ReadWrapperArtifact(filePath)
+	if isWrapper {
+		if !managedDir.Contains(artifactPath) { return "", false }
+		return artifactPath, true
+	}
+
+	return readCopyArtifact(filePath, managedDir)
+}
+
+type WriteWrapper struct {
+	Script WrapperScript
+	DestinationPath string
+	ManagedDir ManagedDir
+	Clobber ClobberPolicy
+}
+
+func (self WriteWrapper) Execute(ctx context.Context) (string, error) {
+	backupMsg, err := clearLinkDestination(self.DestinationPath, self.ManagedDir, self.Clobber)
+	if err != nil {
+		return "", fmt.Errorf(
+			"Writing wrapper script failed while replacing existing file: %w",
+			err,
+		)
+	}
+
+	err = run.VerifyDirExists(path.Dir(self.DestinationPath))
+	if err != nil { return "", fmt.Errorf("Error creating parent dir for wrapper: %w", err) }
+	err = os.WriteFile(self.DestinationPath, []byte(self.Script.Contents()), 0o755)
+	if err != nil { return "", fmt.Errorf("Writing wrapper script failed: %w", err) }
+	// the mode given to WriteFile is subject to the umask
+	err = os.Chmod(self.DestinationPath, 0o755)
+	if err != nil { return "", fmt.Errorf("Setting wrapper script permissions failed: %w", err) }
+
+	return appendDetail("Wrote wrapper script", backupMsg), nil
+}
+
+func (self WriteWrapper) Describe() OpDescription {
+	contextLines := []string{
+		fmt.Sprintf("runs: %s", self.Script.ArtifactPath),
+		fmt.Sprintf("at: %s", self.DestinationPath),
+		fmt.Sprintf("existing files: %s", self.Clobber.describe()),
+		"contents:",
+	}
+	contextLines = append(contextLines, indentedLines(self.Script.Contents())...)
+
+	return OpDescription{
+		TopLine: "Write app wrapper script",
+		ContextLines: contextLines,
+	}
+}
@@ -0,0 +1,72 @@
+package ops
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/lorentzforces/selfman/internal/run"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapperRunsArtifactWithEnvAndArgs(t *testing.T) {
+	managedDir := t.TempDir()
+	binDir := t.TempDir()
+	workingDir := t.TempDir()
+	artifact := path.Join(managedDir, "app---1.0")
+	run.AssertNoErr(os.WriteFile(
+		artifact,
+		[]byte("#!/bin/sh\necho \"$GREETING\" \"$(pwd)\" \"$@\"\n"),
+		0o755,
+	))
+	wrapperPath := path.Join(binDir, "app")
+
+	_, err := WriteWrapper{
+		Script: WrapperScript{
+			ArtifactPath: artifact,
+			Env: map[string]string{ "GREETING": "it's $HOME" },
+			WorkingDir: workingDir,
+			Args: []string{ "--default" },
+		},
+		DestinationPath: wrapperPath,
+		ManagedDir: testManagedDir(managedDir),
+	}.Execute(t.Context())
+	assert.NoError(t, err)
+	run.BailIfFailed(t)
+
+	output, err := exec.Command(wrapperPath, "extra arg").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "it's $HOME " + workingDir + " --default extra arg\n", string(output))
+
+	target, managed := ManagedLinkTarget(wrapperPath, testManagedDir(managedDir))
+	assert.True(t, managed)
+	assert.Equal(t, artifact, target)
+
+	_, managed = ManagedLinkTarget(wrapperPath, testManagedDir(t.TempDir()))
+	assert.False(t, managed, "Wrappers running files outside the managed dir are not selfman's")
+}
+
+func TestLinkArtifactReplacesWrapperScripts(t *testing.T) {
+	managedDir := t.TempDir()
+	binDir := t.TempDir()
+	artifact := path.Join(managedDir, "app---1.0")
+	run.AssertNoErr(os.WriteFile(artifact, []byte("selfman"), 0o755))
+	linkPath := path.Join(binDir, "app")
+	run.AssertNoErr(os.WriteFile(
+		linkPath,
+		[]byte(WrapperScript{ ArtifactPath: artifact }.Contents()),
+		0o755,
+	))
+
+	_, err := LinkArtifact{
+		SourcePath: artifact,
+		DestinationPath: linkPath,
+		ManagedDir: testManagedDir(managedDir),
+	}.Execute(t.Context())
+	assert.NoError(t, err, "Wrapper scripts written by selfman are safe to replace")
+
+	target, err := os.Readlink(linkPath)
+	assert.NoError(t, err)
+	assert.Equal(t, artifact, target)
+}
@@ -0,0 +1,61 @@
+package run
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Where commands run with a stream prefix (see CmdOutput) copy their output. Nil (the default)
+// means output is only captured.
+var cmdOutputStream io.Writer
+
+// Turns on streaming of command output to the given writer (or off, if it is nil). This should be
+// set before any commands are run, rather than while they are running.
+func StreamCmdOutput(dest io.Writer) {
+	cmdOutputStream = dest
+}
+
+// A command's stdout and stderr are written to from separate goroutines, so each gets its own
+// writer (to keep partial lines apart) but they share a lock on the destination.
+func newStreamPair(dest io.Writer, prefix string) (*prefixedLineWriter, *prefixedLineWriter) {
+	lock := &sync.Mutex{}
+	linePrefix := []byte("[" + prefix + "] ")
+	return &prefixedLineWriter{ dest: dest, prefix: linePrefix, lock: lock },
+		&prefixedLineWriter{ dest: dest, prefix: linePrefix, lock: lock }
+}
+
+// Writes complete lines to its destination, each starting with a prefix.
+type prefixedLineWriter struct {
+	dest io.Writer
+	prefix []byte
+	lock *sync.Mutex
+	// The start of a line which has not been ended yet
+	partial []byte
+}
+
+func (self *prefixedLineWriter) Write(data []byte) (int, error) {
+	self.partial = append(self.partial, data...)
+	lastNewline := bytes.LastIndexByte(self.partial, '\n')
+	if lastNewline < 0 { return len(data), nil }
+
+	lines := self.partial[:lastNewline + 1]
+	var buf bytes.Buffer
+	for line := range bytes.Lines(lines) {
+		buf.Write(self.prefix)
+		buf.Write(line)
+	}
+	self.partial = bytes.Clone(self.partial[lastNewline + 1:])
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	// the stream is only for display, so failing to write to it must not fail the command
+	self.dest.Write(buf.Bytes())
+	return len(data), nil
+}
+
+// Writes out any unfinished last line.
+func (self *prefixedLineWriter) flush() {
+	if len(self.partial) == 0 { return }
+	self.Write([]byte("\n"))
+}
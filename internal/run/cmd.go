@@ -2,25 +2,47 @@ package run
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"os"
 	"os/exec"
+	"path"
+	"regexp"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 type CmdError struct {
 	baseError error
 	stdErr string
+	// Only set for commands run with a log file
+	logPath string
+	outputTail string
 }
 
 func errorFrom(baseError error, stdErr string) CmdError {
 	return CmdError{
-		baseError,
-		stdErr,
+		baseError: baseError,
+		stdErr: stdErr,
 	}
 }
 
 func (self *CmdError) Error() string {
+	if len(self.logPath) > 0 {
+		return fmt.Sprintf(
+			"Command run error (%s)\n" +
+				"LAST %d LINES OF OUTPUT:\n%s\n" +
+				"Full output is in log file: %s",
+			self.baseError.Error(),
+			logTailLines,
+			self.outputTail,
+			self.logPath,
+		)
+	}
 	return fmt.Sprintf(
 		"Command run error (%s)\n" +
 			"CMD ERR OUTPUT:\n%s",
@@ -29,6 +51,11 @@ func (self *CmdError) Error() string {
 	)
 }
 
+// The log file holding the command's full output, if it was run with one.
+func (self *CmdError) LogPath() string {
+	return self.logPath
+}
+
 func (self *CmdError) ErrorOutput() string {
 	return self.stdErr
 }
@@ -43,11 +70,23 @@ func (self CmdError) Unwrap() error {
 	return self.baseError
 }
 
+// The cause of cancellation when the user interrupts selfman (e.g. with Ctrl-C).
+var ErrInterrupted = errors.New("Interrupted")
+
+// The error for anything which was stopped after running longer than the given timeout.
+func TimeoutError(timeout time.Duration, source string) error {
+	return fmt.Errorf("Timed out after %s (limit set by %s)", timeout, source)
+}
+
 type cmdRun struct {
 	name string
 	args []string
-	timeoutSeconds *int
+	ctx context.Context
+	timeout time.Duration
+	timeoutSource string
 	workingDir string
+	env Env
+	output CmdOutput
 }
 
 type cmdRunOption func(*cmdRun)
@@ -56,7 +95,7 @@ func NewCmd(name string, ops ...cmdRunOption) *cmdRun {
 	c := &cmdRun{
 		name: name,
 		args: make([]string, 0),
-		timeoutSeconds: nil,
+		ctx: context.Background(),
 	}
 
 	for _, op := range ops {
@@ -72,9 +111,19 @@ func WithArgs(args ...string) cmdRunOption {
 	}
 }
 
-func WithTimeout(seconds int) cmdRunOption {
+// Stops the command if the context is cancelled. The command's error is then the context's cause.
+func WithContext(ctx context.Context) cmdRunOption {
 	return func(c *cmdRun) {
-		c.timeoutSeconds = &seconds
+		c.ctx = ctx
+	}
+}
+
+// Stops the command if it runs for longer than the timeout. The source of the timeout (e.g.
+// "network-timeout setting") is named in the resulting error.
+func WithTimeout(timeout time.Duration, source string) cmdRunOption {
+	return func(c *cmdRun) {
+		c.timeout = timeout
+		c.timeoutSource = source
 	}
 }
 
@@ -84,30 +133,223 @@ func WithWorkingDir(path string) cmdRunOption {
 	}
 }
 
+// Sets a single environment variable for the command.
+func WithEnv(name, value string) cmdRunOption {
+	return func(c *cmdRun) {
+		if c.env.Vars == nil {
+			c.env.Vars = make(map[string]string, 1)
+		}
+		c.env.Vars[name] = value
+	}
+}
+
+// Runs the command with the given environment. Any variables already set for the command are kept
+// (unless the environment sets them too).
+func WithEnvironment(env Env) cmdRunOption {
+	return func(c *cmdRun) {
+		vars := maps.Clone(c.env.Vars)
+		if vars == nil {
+			vars = make(map[string]string, len(env.Vars))
+		}
+		maps.Copy(vars, env.Vars)
+		c.env = env
+		c.env.Vars = vars
+	}
+}
+
+// Where a command's output goes, besides being captured. Empty fields are ignored.
+type CmdOutput struct {
+	// Everything the command prints (stdout and stderr, as they are interleaved) is written to a
+	// log file at this path. The file is appended to if it already exists, so several commands may
+	// share one log.
+	LogPath string
+	// If command output streaming is turned on (see StreamCmdOutput), the command's output is
+	// copied there as it is produced, with each line starting with this prefix in brackets.
+	StreamPrefix string
+}
+
+func WithOutput(output CmdOutput) cmdRunOption {
+	return func(c *cmdRun) {
+		c.output = output
+	}
+}
+
 func (self *cmdRun) Exec() (string, error) {
-	var cmd *exec.Cmd
-	if self.timeoutSeconds == nil {
-		cmd = exec.Command(self.name, self.args...)
-	} else {
-		ctx, cancel := context.WithTimeout(
-			context.Background(),
-			time.Duration(*self.timeoutSeconds) * time.Second,
+	// exec looks up commands using selfman's own PATH, which may not be the command's
+	name := self.name
+	if !self.env.IsEmpty() {
+		if foundPath, found := self.env.LookPath(name); found {
+			name = foundPath
+		}
+	}
+
+	ctx := self.ctx
+	if self.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(
+			ctx,
+			self.timeout,
+			TimeoutError(self.timeout, self.timeoutSource),
 		)
 		defer cancel()
-		cmd = exec.CommandContext(ctx, self.name, self.args...)
 	}
 
+	cmd := exec.CommandContext(ctx, name, self.args...)
+	// The command gets its own process group, so that anything it starts (e.g. the compilers a
+	// build script runs) can be stopped along with it. Processes which ignore being asked to stop
+	// are killed after a grace period.
+	cmd.SysProcAttr = &syscall.SysProcAttr{ Setpgid: true }
+	stopRequested := make(chan time.Time, 1)
+	cmd.Cancel = func() error {
+		stopRequested <- time.Now()
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	// Go only kills the command itself once this has passed, the rest of its group is handled below
+	cmd.WaitDelay = cmdStopGracePeriod
+
 	cmd.Dir = self.workingDir
+	if !self.env.IsEmpty() {
+		cmd.Env = self.env.Resolve()
+	}
 
 	stdOut := &strings.Builder{}
 	stdErr := &strings.Builder{}
-	cmd.Stdout = stdOut
-	cmd.Stderr = stdErr
+	stdOutDests := []io.Writer{ stdOut }
+	stdErrDests := []io.Writer{ stdErr }
+
+	var log *cmdLog
+	if len(self.output.LogPath) > 0 {
+		var err error
+		log, err = openCmdLog(self.output.LogPath, name, self.args, self.workingDir)
+		if err != nil { return "", err }
+		defer log.close()
+		stdOutDests = append(stdOutDests, log)
+		stdErrDests = append(stdErrDests, log)
+	}
+
+	var streams []*prefixedLineWriter
+	if len(self.output.StreamPrefix) > 0 && cmdOutputStream != nil {
+		outStream, errStream := newStreamPair(cmdOutputStream, self.output.StreamPrefix)
+		streams = []*prefixedLineWriter{ outStream, errStream }
+		stdOutDests = append(stdOutDests, outStream)
+		stdErrDests = append(stdErrDests, errStream)
+	}
+
+	cmd.Stdout = io.MultiWriter(stdOutDests...)
+	cmd.Stderr = io.MultiWriter(stdErrDests...)
 
 	err := cmd.Run()
+	select {
+	case stoppedAt := <-stopRequested: {
+		stopProcessGroup(cmd.Process.Pid, stoppedAt.Add(cmdStopGracePeriod))
+	}
+	default:
+	}
+	for _, stream := range streams {
+		stream.flush()
+	}
+	if log != nil {
+		log.finish(err)
+	}
+	if err != nil && ctx.Err() != nil {
+		err = context.Cause(ctx)
+	}
 	if err != nil {
 		cmdError := errorFrom(err, stdErr.String())
+		if log != nil {
+			cmdError.logPath = self.output.LogPath
+			cmdError.outputTail = log.tail()
+		}
 		return stdOut.String(), &cmdError
 	}
 	return stdOut.String(), nil
 }
+
+// Words containing any of these characters would need quoting to be pasted into a shell.
+var shellSpecialCharPattern = regexp.MustCompile(`[^-A-Za-z0-9_./=:@%+,]`)
+
+const cmdStopGracePeriod = 5 * time.Second
+
+// Waits for every process in the group to exit, killing any which are still running at the
+// deadline. The command itself may have exited while processes it started ignore being stopped.
+func stopProcessGroup(groupId int, deadline time.Time) {
+	for time.Now().Before(deadline) {
+		// fails once no process is left in the group
+		if syscall.Kill(-groupId, 0) != nil { return }
+		time.Sleep(50 * time.Millisecond)
+	}
+	_ = syscall.Kill(-groupId, syscall.SIGKILL)
+}
+
+// How many lines of a logged command's output are included in its error.
+const logTailLines = 20
+
+// A command's log file. Stdout and stderr are written from separate goroutines, so writes are
+// serialized here. The combined output is also kept, so that its tail can go in error messages.
+type cmdLog struct {
+	mu sync.Mutex
+	file *os.File
+	combined strings.Builder
+}
+
+func openCmdLog(logPath, name string, args []string, workingDir string) (*cmdLog, error) {
+	err := VerifyDirExists(path.Dir(logPath))
+	if err != nil { return nil, fmt.Errorf("Error creating log dir: %w", err) }
+	file, err := os.OpenFile(logPath, os.O_WRONLY | os.O_CREATE | os.O_APPEND, 0o644)
+	if err != nil { return nil, fmt.Errorf("Error opening log file: %w", err) }
+
+	quotedCmd := make([]string, 0, len(args) + 1)
+	for _, part := range append([]string{ name }, args...) {
+		if len(part) > 0 && nil == shellSpecialCharPattern.FindStringIndex(part) {
+			quotedCmd = append(quotedCmd, part)
+		} else {
+			quotedCmd = append(quotedCmd, ShellQuote(part))
+		}
+	}
+	header := fmt.Sprintf(
+		"=== %s\n=== $ %s\n",
+		time.Now().Format(time.RFC3339),
+		strings.Join(quotedCmd, " "),
+	)
+	if len(workingDir) > 0 {
+		header += fmt.Sprintf("=== in: %s\n", workingDir)
+	}
+	_, err = file.WriteString(header)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Error writing log file: %w", err)
+	}
+	return &cmdLog{ file: file }, nil
+}
+
+func (self *cmdLog) Write(data []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.combined.Write(data)
+	return self.file.Write(data)
+}
+
+func (self *cmdLog) finish(runErr error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := "success"
+	if runErr != nil {
+		result = runErr.Error()
+	}
+	// the log is best-effort once the command has run, so a failed write here is not reported
+	fmt.Fprintf(self.file, "=== finished: %s\n\n", result)
+}
+
+func (self *cmdLog) close() {
+	self.file.Close()
+}
+
+func (self *cmdLog) tail() string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	lines := strings.Split(strings.TrimRight(self.combined.String(), "\n"), "\n")
+	if len(lines) > logTailLines {
+		lines = lines[len(lines) - logTailLines:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,77 @@
+package run
+
+import (
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The environment a command runs with, when it should differ from the one selfman inherited.
+type Env struct {
+	// Set for the command, overriding any inherited values
+	Vars map[string]string
+	// Dirs put at the front of PATH (after Vars are applied)
+	PathPrepend []string
+	// Start from a minimal environment instead of inheriting selfman's
+	Clean bool
+}
+
+// Variables carried over into a clean environment, since many tools misbehave without them.
+var cleanEnvKeptVars = []string{ "HOME", "USER", "LOGNAME", "LANG", "TERM", "TMPDIR" }
+
+const cleanEnvPath = "/usr/local/bin:/usr/bin:/bin"
+
+func (self Env) IsEmpty() bool {
+	return len(self.Vars) == 0 && len(self.PathPrepend) == 0 && !self.Clean
+}
+
+// The full environment for a command, as "NAME=value" pairs sorted by name.
+func (self Env) Resolve() []string {
+	vars := make(map[string]string)
+	if self.Clean {
+		for _, name := range cleanEnvKeptVars {
+			if value, present := os.LookupEnv(name); present {
+				vars[name] = value
+			}
+		}
+		vars["PATH"] = cleanEnvPath
+	} else {
+		for _, pair := range os.Environ() {
+			name, value, _ := strings.Cut(pair, "=")
+			vars[name] = value
+		}
+	}
+
+	maps.Copy(vars, self.Vars)
+	if len(self.PathPrepend) > 0 {
+		pathDirs := slices.Clone(self.PathPrepend)
+		// an empty entry would put the working dir on PATH
+		if len(vars["PATH"]) > 0 {
+			pathDirs = append(pathDirs, vars["PATH"])
+		}
+		vars["PATH"] = strings.Join(pathDirs, string(os.PathListSeparator))
+	}
+
+	resolved := make([]string, 0, len(vars))
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		resolved = append(resolved, name + "=" + vars[name])
+	}
+	return resolved
+}
+
+// The value of PATH in the resolved environment.
+func (self Env) Path() string {
+	for _, pair := range self.Resolve() {
+		if value, isPath := strings.CutPrefix(pair, "PATH="); isPath { return value }
+	}
+	return ""
+}
+
+// Finds an executable the way a command run with this environment would.
+func (self Env) LookPath(executable string) (string, bool) {
+	if strings.Contains(executable, string(os.PathSeparator)) { return executable, true }
+	found := FindExecutablesOnPath(executable, self.Path())
+	if len(found) == 0 { return "", false }
+	return found[0], true
+}
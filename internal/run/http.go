@@ -1,46 +1,77 @@
 package run
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	urlPkg "net/url"
 	"os"
 	"path"
-	"time"
 )
 
-var httpClient = &http.Client{
-	Timeout: 15 * time.Second,
-}
-
 // Fetch a file from the given URL using an http GET request. If no error is encountered, returns
-// the path of the resulting file (which will be created in a temp directory). File name is determined from the path component of the given
-// URL.
-func GetFileFromUrl(url string) (string, error) {
+// the path of the resulting file (which will be created in a temp directory). File name is
+// determined from the path component of the given URL.
+func GetFileFromUrl(ctx context.Context, url string, network NetworkPolicy) (string, error) {
 	parsedUrl, err := urlPkg.Parse(url)
 	if err != nil { return "", fmt.Errorf("Invalid URL: %s", url) }
+	destPath := path.Join(os.TempDir(), path.Base(parsedUrl.Path))
+
+	// the timeout covers the whole download, not just the initial response
+	httpClient := &http.Client{ Timeout: network.Timeout }
+	err = network.Retry(ctx, func() error {
+		return downloadFile(ctx, httpClient, url, destPath, network)
+	})
+	if err != nil { return "", err }
+	return destPath, nil
+}
 
-	response, err := httpClient.Get(url)
-	if err != nil { return "", fmt.Errorf("Failed to fetch from URL (%s): %w", url, err) }
+func downloadFile(
+	ctx context.Context,
+	httpClient *http.Client,
+	url string,
+	destPath string,
+	network NetworkPolicy,
+) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil { return Permanent(fmt.Errorf("Invalid URL: %s", url)) }
+	response, err := httpClient.Do(request)
+	if ctx.Err() != nil { return context.Cause(ctx) }
+	if isTimeout(err) { return TimeoutError(network.Timeout, network.TimeoutSource) }
+	if err != nil { return fmt.Errorf("Failed to fetch from URL (%s): %w", url, err) }
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return "", fmt.Errorf(
+		err := fmt.Errorf(
 			"Fetch responded with non-200 status code (%d from %s)",
 			response.StatusCode, url,
 		)
+		// client errors will not go away by asking again, except for these
+		isClientError := response.StatusCode >= 400 && response.StatusCode < 500
+		canRetry := response.StatusCode == http.StatusRequestTimeout ||
+			response.StatusCode == http.StatusTooManyRequests
+		if isClientError && !canRetry { return Permanent(err) }
+		return err
 	}
 
-	destPath := path.Join(os.TempDir(), path.Base(parsedUrl.Path))
 	destFile, err := os.Create(destPath)
 	if err != nil {
-		return "", fmt.Errorf("Failed to create destination file for download: %w", err)
+		return Permanent(fmt.Errorf("Failed to create destination file for download: %w", err))
 	}
 	defer destFile.Close()
 
 	_, err = io.Copy(destFile, response.Body)
-	if err != nil { return "", fmt.Errorf("Error while copying response buffer to file: %w", err) }
+	if ctx.Err() != nil { return context.Cause(ctx) }
+	if isTimeout(err) { return TimeoutError(network.Timeout, network.TimeoutSource) }
+	if err != nil { return fmt.Errorf("Error while copying response buffer to file: %w", err) }
 
-	return destPath, nil
+	return nil
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
@@ -0,0 +1,34 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Acquires an exclusive lock on the file at the given path, creating the file if necessary. The
+// lock is held until the returned release function is called, and is released automatically by
+// the OS if the process exits without releasing it.
+func AcquireFileLock(lockPath string) (release func(), err error) {
+	lockFile, err := os.OpenFile(lockPath, os.O_CREATE | os.O_RDWR, 0o644)
+	if err != nil { return nil, fmt.Errorf("Could not open lock file: %w", err) }
+
+	err = syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX | syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		lockFile.Close()
+		return nil, fmt.Errorf(
+			"Another selfman process is currently running (holding lock \"%s\")",
+			lockPath,
+		)
+	}
+	if err != nil {
+		lockFile.Close()
+		return nil, fmt.Errorf("Could not acquire lock \"%s\": %w", lockPath, err)
+	}
+
+	return func() {
+		syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+		lockFile.Close()
+	}, nil
+}
@@ -0,0 +1,77 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Limits for an operation which goes over the network, and how it is retried if it fails.
+type NetworkPolicy struct {
+	// Each attempt is abandoned after this long
+	Timeout time.Duration
+	// Where the timeout came from (e.g. "network-timeout setting"), so that timeout errors can say
+	// which limit was hit
+	TimeoutSource string
+	// How many more attempts are made after the first one fails
+	Retries int
+	// How long to wait before the first retry. The wait doubles for each retry after that.
+	Backoff time.Duration
+}
+
+// An error which retrying will not fix (e.g. a URL which does not exist).
+type permanentError struct {
+	err error
+}
+
+func (self permanentError) Error() string {
+	return self.err.Error()
+}
+
+func (self permanentError) Unwrap() error {
+	return self.err
+}
+
+// Marks an error as one which should not be retried.
+func Permanent(err error) error {
+	return permanentError{ err }
+}
+
+// Makes attempts until one succeeds, one fails with a permanent error, all retries are used up,
+// or the context is cancelled. If every attempt fails, the last attempt's error is returned.
+func (self NetworkPolicy) Retry(ctx context.Context, attempt func() error) error {
+	attempts := 0
+	for {
+		err := attempt()
+		attempts++
+		if err == nil { return nil }
+
+		var permanent permanentError
+		if errors.As(err, &permanent) { return permanent.err }
+		if ctx.Err() != nil { return err }
+		if attempts > self.Retries {
+			if attempts == 1 { return err }
+			return fmt.Errorf("Failed after %d attempts: %w", attempts, err)
+		}
+
+		select {
+		case <-ctx.Done(): return context.Cause(ctx)
+		case <-time.After(self.backoff(attempts)):
+		}
+	}
+}
+
+const maxBackoff = 5 * time.Minute
+
+// The wait after the given number of failed attempts. Up to half again of the wait is added at
+// random, so that many clients failing at once do not all retry at once.
+func (self NetworkPolicy) backoff(failedAttempts int) time.Duration {
+	if self.Backoff <= 0 { return 0 }
+	wait := self.Backoff << (failedAttempts - 1)
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait + rand.N(wait / 2 + 1)
+}
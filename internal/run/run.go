@@ -1,10 +1,13 @@
 package run
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/lorentzforces/fresh-err/fresherr"
@@ -60,6 +63,11 @@ func StrPtr(str string) *string {
 	return &str
 }
 
+// Returns a pointer to a passed int, like StrPtr.
+func IntPtr(number int) *int {
+	return &number
+}
+
 var ErrNotImplemented = fmt.Errorf("Not yet implemented")
 
 func VerifyDirExists(dirPath string) error {
@@ -101,6 +109,14 @@ func MoveFile(srcPath, destPath string) error {
 	isIncompatibleRenameError := strings.Contains(err.Error(), "invalid cross-device link")
 	if !isIncompatibleRenameError { return err }
 
+	if stat, statErr := os.Lstat(srcPath); statErr == nil && stat.IsDir() {
+		err = copyDirTree(srcPath, destPath)
+		if err != nil { return fmt.Errorf("move dir with copy: %w", err) }
+		err = os.RemoveAll(srcPath)
+		if err != nil { return fmt.Errorf("move dir with copy: couldn't remove source dir: %w", err) }
+		return nil
+	}
+
 	inputFile, err := os.Open(srcPath)
 	if err != nil {
 		return fmt.Errorf("move file with copy: couldn't open source file: %w", err)
@@ -127,3 +143,129 @@ func MoveFile(srcPath, destPath string) error {
 
 	return nil
 }
+
+// Moves a file or directory to destPath, replacing anything already there. The move is made to a
+// temporary path next to destPath first, so that destPath is only ever swapped from the old
+// contents to the complete new ones (a directory cannot be renamed onto a non-empty one, and a
+// copy across filesystems would otherwise write into the old file in place).
+func MoveReplacing(srcPath, destPath string) error {
+	destDir, destName := path.Split(destPath)
+	// hidden names, so that a leftover from an interrupted move is not mistaken for anything else
+	newPath := path.Join(destDir, "." + destName + ".selfman-new")
+	oldPath := path.Join(destDir, "." + destName + ".selfman-old")
+	for _, leftoverPath := range []string{ newPath, oldPath } {
+		if err := os.RemoveAll(leftoverPath); err != nil { return err }
+	}
+
+	err := MoveFile(srcPath, newPath)
+	if err != nil { return err }
+
+	stat, err := os.Lstat(destPath)
+	if err != nil || !stat.IsDir() {
+		// renaming over a file (or nothing) replaces it in one step
+		return os.Rename(newPath, destPath)
+	}
+
+	err = os.Rename(destPath, oldPath)
+	if err != nil { return fmt.Errorf("Could not move existing dir out of the way: %w", err) }
+	err = os.Rename(newPath, destPath)
+	if err != nil {
+		// put the old dir back rather than leave nothing at destPath
+		os.Rename(oldPath, destPath)
+		return err
+	}
+	return os.RemoveAll(oldPath)
+}
+
+// Copies a directory and everything under it, keeping file modes and recreating (rather than
+// following) any symlinks.
+func copyDirTree(srcDir, destDir string) error {
+	return filepath.WalkDir(srcDir, func(srcPath string, entry os.DirEntry, err error) error {
+		if err != nil { return err }
+		relPath, err := filepath.Rel(srcDir, srcPath)
+		if err != nil { return err }
+		destPath := filepath.Join(destDir, relPath)
+
+		info, err := entry.Info()
+		if err != nil { return err }
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(destPath, info.Mode().Perm())
+		case info.Mode() & os.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(srcPath)
+			if err != nil { return err }
+			return os.Symlink(linkTarget, destPath)
+		default:
+			return copyFile(srcPath, destPath, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(srcPath, destPath string, mode os.FileMode) error {
+	inputFile, err := os.Open(srcPath)
+	if err != nil { return err }
+	defer inputFile.Close()
+
+	outputFile, err := os.OpenFile(destPath, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, mode)
+	if err != nil { return err }
+	defer outputFile.Close()
+
+	_, err = io.Copy(outputFile, inputFile)
+	return err
+}
+
+// Whether the given path is the given dir, or is located somewhere underneath it. Paths are
+// compared lexically, without resolving any symlinks.
+func IsPathWithin(filePath, dirPath string) bool {
+	relPath, err := filepath.Rel(dirPath, filePath)
+	if err != nil { return false }
+	return relPath != ".." && !strings.HasPrefix(relPath, "../")
+}
+
+// Reads the target of the symlink at the given path. If the target is relative, it is resolved
+// relative to the directory containing the link.
+func ReadLinkAbs(linkPath string) (string, error) {
+	target, err := os.Readlink(linkPath)
+	if err != nil { return "", err }
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir(linkPath), target)
+	}
+	return target, nil
+}
+
+// Finds every executable file with the given name in the directories of the given PATH-style list,
+// in the order a shell would consider them (so the first result is the one which would be run).
+func FindExecutablesOnPath(name, pathEnv string) []string {
+	found := make([]string, 0)
+	seenDirs := make(map[string]bool)
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if len(dir) == 0 { continue }
+		dir = filepath.Clean(dir)
+		if seenDirs[dir] { continue }
+		seenDirs[dir] = true
+
+		candidate := filepath.Join(dir, name)
+		stat, err := os.Stat(candidate)
+		if err != nil || !stat.Mode().IsRegular() || stat.Mode() & 0o111 == 0 { continue }
+		found = append(found, candidate)
+	}
+	return found
+}
+
+// Quotes a string so that a POSIX shell will treat it as a single literal word.
+func ShellQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+// Returns the hex-encoded SHA-256 hash of the file's contents.
+func FileSha256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil { return "", err }
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil { return "", err }
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}